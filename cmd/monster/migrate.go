@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/orm"
+	migration "github.com/go-monsters/monster/pkg/orm/migrate"
+	_ "github.com/go-monsters/monster/pkg/orm/mysql"
+	_ "github.com/go-monsters/monster/pkg/orm/postgresql"
+	_ "github.com/go-monsters/monster/pkg/orm/sqlite"
+)
+
+const migrateUsage = `usage: monster migrate [flags] up|down [steps]|status|create <name>
+
+flags:
+`
+
+func migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	driver := fs.String("driver", "mysql", "database driver, one of the orm registered impls: mysql, postgresql (or postgres) or sqlite")
+	dsn := fs.String("dsn", os.Getenv("MONSTER_DSN"), "database address, defaults to $MONSTER_DSN")
+	dir := fs.String("dir", "migrations", "directory of the migration files")
+	table := fs.String("table", migration.DefaultTable, "table of the applied migrations")
+	dryRun := fs.Bool("dry-run", false, "print the migrations instead of applying them")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cmd := fs.Arg(0)
+	if cmd == "create" {
+		if fs.NArg() < 2 {
+			return merror.Error("migrate create needs the name of the migration")
+		}
+		up, down, err := migration.Create(*dir, fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created %s\ncreated %s\n", up, down)
+		return nil
+	}
+
+	ctx := context.Background()
+	db, err := open(ctx, *driver, *dsn)
+	if err != nil {
+		return err
+	}
+	migrations, err := migration.LoadDir(*dir)
+	if err != nil {
+		return err
+	}
+	m := migration.New(db, migrations...)
+	m.Table = *table
+	m.DryRun = *dryRun
+
+	switch cmd {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		steps := 1
+		if fs.NArg() > 1 {
+			if steps, err = strconv.Atoi(fs.Arg(1)); err != nil {
+				return merror.Wrapf(err, "invalid number of steps: %s", fs.Arg(1))
+			}
+		}
+		return m.Down(ctx, steps)
+	case "status":
+		return status(ctx, m)
+	}
+	fs.Usage()
+	os.Exit(2)
+	return nil
+}
+
+func status(ctx context.Context, m *migration.Migrator) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", ""
+		if s.Applied {
+			state, appliedAt = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if s.Drifted {
+			state = "drifted"
+		}
+		if s.Missing {
+			state = "missing"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
+
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+func open(ctx context.Context, driver, dsn string) (orm.Database, error) {
+	if dsn == "" {
+		return nil, merror.Error("the database address is missing, use -dsn or $MONSTER_DSN")
+	}
+	if driver == "postgres" {
+		driver = "postgresql"
+	}
+	config, err := json.Marshal(orm.Config{DSN: dsn})
+	if err != nil {
+		return nil, err
+	}
+	db, err := orm.NewDatabase(driver, string(config))
+	if err != nil {
+		return nil, err
+	}
+	// the adapters connect lazily, a bad address must fail here rather than in the first query
+	if p, ok := db.(pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
@@ -0,0 +1,298 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/cache"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	DefaultPath   = filepath.Join("cache", "monster.db")
+	DefaultBucket = "monster"
+	DefaultEvery  = 60 // 1 minute
+	// DefaultVacuumBatch is the number of keys the expiration check reads and deletes per transaction.
+	DefaultVacuumBatch = 1000
+)
+
+// value wraps the cached value so that gob keeps its concrete type.
+type value struct {
+	Data interface{}
+}
+
+type Cache struct {
+	sync.Mutex
+	db     *bolt.DB
+	path   string
+	bucket []byte
+	done   chan struct{}
+	Every  int // run an expiration check Every clock time
+}
+
+func NewBoltCache() cache.Cache {
+	return &Cache{}
+}
+
+func (c *Cache) GetClient() interface{} {
+	return c.db
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (interface{}, error) {
+	var data []byte
+	err := c.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(c.bucket).Get([]byte(key))
+		if raw == nil {
+			return cache.ErrKeyNotExist
+		}
+		if isExpired(raw) {
+			return cache.ErrKeyExpired
+		}
+		// the slice is only valid inside the transaction
+		data = append([]byte(nil), raw[8:]...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var v value
+	if err := gob.NewDecoder(bytes.NewBuffer(data)).Decode(&v); err != nil {
+		return nil, merror.Wrapf(err, "could not decode the value of the key: %s", key)
+	}
+	if err := cache.Negative(key, v.Data); err != nil {
+		return nil, err
+	}
+	return v.Data, nil
+}
+
+func (c *Cache) GetMulti(ctx context.Context, keys []string) ([]interface{}, error) {
+	rc := make([]interface{}, len(keys))
+	keysErr := make([]string, 0)
+
+	for i, ki := range keys {
+		val, err := c.Get(ctx, ki)
+		if err != nil {
+			keysErr = append(keysErr, fmt.Sprintf("key [%s] error: %s", ki, err.Error()))
+			continue
+		}
+		rc[i] = val
+	}
+
+	if len(keysErr) == 0 {
+		return rc, nil
+	}
+	return rc, merror.Error(strings.Join(keysErr, "; "))
+}
+
+func (c *Cache) Put(ctx context.Context, key string, val interface{}, timeout time.Duration) error {
+	gob.Register(val)
+
+	// the entry is the expiry in unix nanoseconds followed by the gob encoded value, 0 means forever
+	buf := bytes.NewBuffer(make([]byte, 8))
+	if err := gob.NewEncoder(buf).Encode(value{Data: val}); err != nil {
+		return merror.Wrapf(err, "could not encode the value of the key: %s", key)
+	}
+	raw := buf.Bytes()
+	// a negative timeout expires the entry at once like the other adapters
+	if timeout != 0 {
+		binary.BigEndian.PutUint64(raw[:8], uint64(time.Now().Add(timeout).UnixNano()))
+	}
+
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(c.bucket).Put([]byte(key), raw)
+	})
+	return merror.Wrapf(err, "could not put the key-value to bolt, key: %s", key)
+}
+
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(c.bucket).Delete([]byte(key))
+	})
+	return merror.Wrapf(err, "could not delete the key-value from bolt, key: %s", key)
+}
+
+func (c *Cache) Scan(ctx context.Context, pattern string, fn cache.ScanFunc) error {
+	// collect the keys first so that fn is free to modify the cache,
+	// they are matched out of the transaction
+	keys := make([]string, 0)
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(c.bucket).ForEach(func(k, raw []byte) error {
+			if !isExpired(raw) {
+				keys = append(keys, string(k))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return merror.Wrapf(err, "could not scan the keys from bolt, pattern: %s", pattern)
+	}
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !cache.MatchPattern(pattern, key) {
+			continue
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start reads the config {"path": "cache/monster.db", "bucket": "monster", "interval": 60}
+// and opens the database file, creating it if needed.
+func (c *Cache) Start(config string) error {
+	var cf map[string]interface{}
+	if err := json.Unmarshal([]byte(config), &cf); err != nil {
+		return merror.Wrapf(err, "invalid config, please check your input: %s", config)
+	}
+
+	c.path = DefaultPath
+	if path, ok := cf["path"].(string); ok && path != "" {
+		c.path = path
+	}
+	c.bucket = []byte(DefaultBucket)
+	if bucket, ok := cf["bucket"].(string); ok && bucket != "" {
+		c.bucket = []byte(bucket)
+	}
+	c.Every = DefaultEvery
+	if interval, ok := cf["interval"].(float64); ok {
+		c.Every = int(interval)
+	}
+
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return merror.Wrapf(err,
+				"could not create directory, please check the config [%s] and file mode.", dir)
+		}
+	}
+	db, err := bolt.Open(c.path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return merror.Wrapf(err, "could not open the bolt database: %s", c.path)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(c.bucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return merror.Wrapf(err, "could not create the bucket: %s", c.bucket)
+	}
+
+	c.db = db
+	c.done = make(chan struct{})
+	go c.vacuum()
+	return nil
+}
+
+// Close stops the expiration check and closes the database file.
+func (c *Cache) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.done == nil {
+		return nil
+	}
+	close(c.done)
+	c.done = nil
+	return c.db.Close()
+}
+
+func (c *Cache) vacuum() {
+	if c.Every < 1 {
+		return
+	}
+	done := c.done
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Duration(c.Every) * time.Second):
+		}
+		c.removeExpired(done)
+	}
+}
+
+// removeExpired walks the bucket by batches of DefaultVacuumBatch keys, each read in a read transaction
+// then deleted in a short write transaction, so the writers are never blocked for a whole walk.
+func (c *Cache) removeExpired(done chan struct{}) {
+	var after []byte
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		var expired [][]byte
+		read := 0
+		err := c.db.View(func(tx *bolt.Tx) error {
+			cur := tx.Bucket(c.bucket).Cursor()
+			k, raw := cur.First()
+			if after != nil {
+				// resume after the last key of the previous batch
+				if k, raw = cur.Seek(after); bytes.Equal(k, after) {
+					k, raw = cur.Next()
+				}
+			}
+			for ; k != nil && read < DefaultVacuumBatch; k, raw = cur.Next() {
+				read++
+				after = append(after[:0], k...)
+				if isExpired(raw) {
+					expired = append(expired, append([]byte(nil), k...))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+
+		if len(expired) > 0 {
+			err = c.db.Update(func(tx *bolt.Tx) error {
+				b := tx.Bucket(c.bucket)
+				for _, k := range expired {
+					// the key may have been put again since it was read
+					if raw := b.Get(k); raw != nil && isExpired(raw) {
+						if err := b.Delete(k); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return
+			}
+		}
+		if read < DefaultVacuumBatch {
+			return
+		}
+	}
+}
+
+func isExpired(raw []byte) bool {
+	if len(raw) < 8 {
+		return true
+	}
+	expiry := int64(binary.BigEndian.Uint64(raw[:8]))
+	// 0 means forever
+	return expiry != 0 && time.Now().UnixNano() > expiry
+}
+
+func init() {
+	cache.RegisterNewCacheImpl("bolt", NewBoltCache)
+}
@@ -0,0 +1,159 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/cache"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type point struct {
+	X, Y int
+}
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+	config, _ := json.Marshal(map[string]interface{}{
+		"path":     filepath.Join(t.TempDir(), "cache", "test.db"),
+		"interval": 0,
+	})
+	c := NewBoltCache().(*Cache)
+	if err := c.Start(string(config)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		want    interface{}
+		wantErr error
+	}{
+		{name: "string", key: "string", want: "value"},
+		{name: "struct", key: "struct", want: point{X: 1, Y: 2}},
+		{name: "forever", key: "forever", want: 42},
+		{name: "miss", key: "missing", wantErr: cache.ErrKeyNotExist},
+		{name: "expired", key: "expired", wantErr: cache.ErrKeyExpired},
+		{name: "negative", key: "negative", wantErr: cache.ErrKeyNegative},
+		{name: "deleted", key: "deleted", wantErr: cache.ErrKeyNotExist},
+	}
+	ctx := context.Background()
+	c := newTestCache(t)
+	_ = c.Put(ctx, "string", "value", time.Minute)
+	_ = c.Put(ctx, "struct", point{X: 1, Y: 2}, time.Minute)
+	_ = c.Put(ctx, "forever", 42, 0)
+	_ = c.Put(ctx, "expired", "value", -time.Minute)
+	_ = c.Put(ctx, "deleted", "value", time.Minute)
+	_ = c.Delete(ctx, "deleted")
+	_ = cache.PutNegative(ctx, c, "negative", "not found", time.Minute)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Get(ctx, tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get(%q) error = %v, want %v", tt.key, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t)
+	_ = c.Put(ctx, "user:1", "a", time.Minute)
+	_ = c.Put(ctx, "user:2", "b", 0)
+	_ = c.Put(ctx, "user:3", "c", -time.Minute)
+	_ = c.Put(ctx, "order:1", "d", time.Minute)
+
+	var keys []string
+	// fn is free to write to the cache
+	err := cache.Scan(ctx, c, "user:*", func(key string) error {
+		keys = append(keys, key)
+		return c.Delete(ctx, key)
+	})
+	sort.Strings(keys)
+	if err != nil || strings.Join(keys, ",") != "user:1,user:2" {
+		t.Errorf("Scan = %v, %v, want [user:1 user:2]", keys, err)
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	defer func(batch int) { DefaultVacuumBatch = batch }(DefaultVacuumBatch)
+	DefaultVacuumBatch = 10
+
+	tests := []struct {
+		name    string
+		expired int
+		live    int
+	}{
+		{name: "empty"},
+		{name: "nothing expired", live: 25},
+		{name: "everything expired", expired: 25},
+		{name: "less than a batch", expired: 3, live: 4},
+		{name: "exactly a batch", expired: 5, live: 5},
+		{name: "many batches", expired: 57, live: 43},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := newTestCache(t)
+			timeouts := make([]time.Duration, 0, tt.expired+tt.live)
+			for i := 0; i < tt.expired; i++ {
+				timeouts = append(timeouts, -time.Minute)
+			}
+			for i := 0; i < tt.live; i++ {
+				timeouts = append(timeouts, time.Minute)
+			}
+			// the expired and live keys are mixed across the batches
+			rand.New(rand.NewSource(1)).Shuffle(len(timeouts), func(i, j int) {
+				timeouts[i], timeouts[j] = timeouts[j], timeouts[i]
+			})
+			for i, timeout := range timeouts {
+				_ = c.Put(ctx, strconv.Itoa(i), i, timeout)
+			}
+
+			c.removeExpired(make(chan struct{}))
+
+			var keys, expired int
+			_ = c.db.View(func(tx *bolt.Tx) error {
+				return tx.Bucket(c.bucket).ForEach(func(k, raw []byte) error {
+					keys++
+					if isExpired(raw) {
+						expired++
+					}
+					return nil
+				})
+			})
+			if keys != tt.live || expired != 0 {
+				t.Errorf("%d keys left with %d expired, want %d live keys", keys, expired, tt.live)
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	c := newTestCache(t)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close error = %v", err)
+	}
+}
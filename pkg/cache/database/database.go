@@ -0,0 +1,232 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/cache"
+	"github.com/go-monsters/monster/pkg/orm"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	DefaultTable = "monster_cache"
+	DefaultEvery = 60 // 1 minute
+)
+
+var (
+	connsMu sync.RWMutex
+	conns   = make(map[string]orm.Database)
+)
+
+// RegisterConnection makes db available to the "database" adapter under name,
+// the adapter config refers to it with the "conn" field.
+func RegisterConnection(name string, db orm.Database) {
+	if db == nil {
+		panic(merror.Error("database cache: Register connection is nil").Error())
+	}
+	connsMu.Lock()
+	defer connsMu.Unlock()
+	if _, ok := conns[name]; ok {
+		panic("database cache: Register called twice for connection " + name)
+	}
+	conns[name] = db
+}
+
+type entry struct {
+	CacheKey  string `gorm:"primaryKey;size:250"`
+	Value     []byte
+	ExpiresAt *time.Time `gorm:"index"`
+}
+
+// value wraps the cached value so that gob keeps its concrete type.
+type value struct {
+	Data interface{}
+}
+
+type Cache struct {
+	db    orm.Database
+	table string
+	Every int // run an expiration cleanup Every clock time
+}
+
+func NewDatabaseCache() cache.Cache {
+	return &Cache{}
+}
+
+// New returns a cache storing its entries through db, Start must still be called with the config.
+func New(db orm.Database) cache.Cache {
+	return &Cache{db: db}
+}
+
+func (c *Cache) GetClient() interface{} {
+	return c.db
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (interface{}, error) {
+	var e entry
+	err := c.conn(ctx).Where("cache_key = ?", key).Take(&e).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cache.ErrKeyNotExist
+	}
+	if err != nil {
+		return nil, merror.Wrapf(err, "could not read the key from the database: %s", key)
+	}
+	return e.decode()
+}
+
+func (c *Cache) GetMulti(ctx context.Context, keys []string) ([]interface{}, error) {
+	rc := make([]interface{}, len(keys))
+
+	var entries []entry
+	if err := c.conn(ctx).Where("cache_key IN ?", keys).Find(&entries).Error; err != nil {
+		return rc, merror.Wrapf(err, "could not read the keys from the database: %s", strings.Join(keys, ","))
+	}
+	found := make(map[string]*entry, len(entries))
+	for i := range entries {
+		found[entries[i].CacheKey] = &entries[i]
+	}
+
+	keysErr := make([]string, 0)
+	for i, ki := range keys {
+		e, ok := found[ki]
+		if !ok {
+			keysErr = append(keysErr, fmt.Sprintf("key [%s] error: %s", ki, cache.ErrKeyNotExist.Error()))
+			continue
+		}
+		val, err := e.decode()
+		if err != nil {
+			keysErr = append(keysErr, fmt.Sprintf("key [%s] error: %s", ki, err.Error()))
+			continue
+		}
+		rc[i] = val
+	}
+
+	if len(keysErr) == 0 {
+		return rc, nil
+	}
+	return rc, merror.Error(strings.Join(keysErr, "; "))
+}
+
+func (c *Cache) Put(ctx context.Context, key string, val interface{}, timeout time.Duration) error {
+	gob.Register(val)
+
+	buf := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(buf).Encode(value{Data: val}); err != nil {
+		return merror.Wrapf(err, "could not encode the value of the key: %s", key)
+	}
+	e := entry{CacheKey: key, Value: buf.Bytes()}
+	// 0 means forever, a negative timeout expires the entry at once like the other adapters
+	if timeout != 0 {
+		expiresAt := time.Now().Add(timeout)
+		e.ExpiresAt = &expiresAt
+	}
+	err := c.conn(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cache_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "expires_at"}),
+	}).Create(&e).Error
+	return merror.Wrapf(err, "could not put the key-value to the database, key: %s", key)
+}
+
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	err := c.conn(ctx).Where("cache_key = ?", key).Delete(&entry{}).Error
+	return merror.Wrapf(err, "could not delete the key-value from the database, key: %s", key)
+}
+
+func (c *Cache) Scan(ctx context.Context, pattern string, fn cache.ScanFunc) error {
+	var keys []string
+	err := c.conn(ctx).Model(&entry{}).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Pluck("cache_key", &keys).Error
+	if err != nil {
+		return merror.Wrapf(err, "could not scan the keys from the database, pattern: %s", pattern)
+	}
+	for _, key := range keys {
+		if !cache.MatchPattern(pattern, key) {
+			continue
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start reads the config {"conn": "name", "table": "monster_cache", "interval": 60},
+// conn is the name given to RegisterConnection and may be omitted if the cache was built by New.
+// The table is created if it doesn't exist and expired entries are removed every interval seconds.
+func (c *Cache) Start(config string) error {
+	var cf map[string]interface{}
+	if err := json.Unmarshal([]byte(config), &cf); err != nil {
+		return merror.Wrapf(err, "invalid config, please check your input: %s", config)
+	}
+
+	if name, ok := cf["conn"].(string); ok {
+		connsMu.RLock()
+		c.db = conns[name]
+		connsMu.RUnlock()
+		if c.db == nil {
+			return merror.Errorf("database cache: unknown connection %s (forgot to register?)", name)
+		}
+	}
+	if c.db == nil {
+		return merror.Errorf(`config must contains "conn" field: %s`, config)
+	}
+
+	c.table = DefaultTable
+	if table, ok := cf["table"].(string); ok && table != "" {
+		c.table = table
+	}
+	c.Every = DefaultEvery
+	if interval, ok := cf["interval"].(float64); ok {
+		c.Every = int(interval)
+	}
+
+	if err := c.db.GetConnection(context.Background()).Table(c.table).AutoMigrate(&entry{}); err != nil {
+		return merror.Wrapf(err, "could not create the cache table: %s", c.table)
+	}
+	go c.vacuum()
+	return nil
+}
+
+func (c *Cache) conn(ctx context.Context) *gorm.DB {
+	return c.db.GetConnection(ctx).Table(c.table)
+}
+
+func (c *Cache) vacuum() {
+	if c.Every < 1 {
+		return
+	}
+	for {
+		<-time.After(time.Duration(c.Every) * time.Second)
+		_ = c.conn(context.Background()).Where("expires_at < ?", time.Now()).Delete(&entry{}).Error
+	}
+}
+
+func (e *entry) decode() (interface{}, error) {
+	if e.ExpiresAt != nil && e.ExpiresAt.Before(time.Now()) {
+		return nil, cache.ErrKeyExpired
+	}
+	var v value
+	if err := gob.NewDecoder(bytes.NewBuffer(e.Value)).Decode(&v); err != nil {
+		return nil, merror.Wrapf(err, "could not decode the value of the key: %s", e.CacheKey)
+	}
+	if err := cache.Negative(e.CacheKey, v.Data); err != nil {
+		return nil, err
+	}
+	return v.Data, nil
+}
+
+func init() {
+	cache.RegisterNewCacheImpl("database", NewDatabaseCache)
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/cache"
+	"github.com/go-monsters/monster/pkg/orm/sqlite"
+)
+
+type point struct {
+	X, Y int
+}
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+	db, err := sqlite.Open(context.Background(), sqlite.Config{InMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	c := New(db).(*Cache)
+	if err := c.Start(`{"interval": 0}`); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		want    interface{}
+		wantErr error
+	}{
+		{name: "string", key: "string", want: "value"},
+		{name: "struct", key: "struct", want: point{X: 1, Y: 2}},
+		{name: "forever", key: "forever", want: 42},
+		{name: "overwritten", key: "overwritten", want: "new"},
+		{name: "miss", key: "missing", wantErr: cache.ErrKeyNotExist},
+		{name: "expired", key: "expired", wantErr: cache.ErrKeyExpired},
+		{name: "negative", key: "negative", wantErr: cache.ErrKeyNegative},
+		{name: "deleted", key: "deleted", wantErr: cache.ErrKeyNotExist},
+	}
+	ctx := context.Background()
+	c := newTestCache(t)
+	puts := []struct {
+		key     string
+		val     interface{}
+		timeout time.Duration
+	}{
+		{"string", "value", time.Minute},
+		{"struct", point{X: 1, Y: 2}, time.Minute},
+		{"forever", 42, 0},
+		{"overwritten", "old", time.Minute},
+		{"overwritten", "new", time.Minute},
+		{"expired", "value", -time.Minute},
+		{"deleted", "value", time.Minute},
+	}
+	for _, p := range puts {
+		if err := c.Put(ctx, p.key, p.val, p.timeout); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cache.PutNegative(ctx, c, "negative", "not found", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, "deleted"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Get(ctx, tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get(%q) error = %v, want %v", tt.key, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMulti(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t)
+	_ = c.Put(ctx, "a", "A", time.Minute)
+	_ = c.Put(ctx, "b", "B", time.Minute)
+
+	got, err := c.GetMulti(ctx, []string{"a", "missing", "b"})
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("GetMulti error = %v, want the missing key reported", err)
+	}
+	if want := []interface{}{"A", nil, "B"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetMulti = %v, want %v", got, want)
+	}
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t)
+	_ = c.Put(ctx, "user:1", "a", time.Minute)
+	_ = c.Put(ctx, "user:2", "b", 0)
+	_ = c.Put(ctx, "user:3", "c", -time.Minute)
+	_ = c.Put(ctx, "order:1", "d", time.Minute)
+
+	var keys []string
+	err := cache.Scan(ctx, c, "user:*", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	sort.Strings(keys)
+	if err != nil || strings.Join(keys, ",") != "user:1,user:2" {
+		t.Errorf("Scan = %v, %v, want [user:1 user:2]", keys, err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	db, err := sqlite.Open(context.Background(), sqlite.Config{InMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	RegisterConnection("database-test", db)
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{name: "registered connection", config: `{"conn": "database-test", "table": "test_cache", "interval": 0}`},
+		{name: "unknown connection", config: `{"conn": "unknown"}`, wantErr: true},
+		{name: "no connection", config: `{}`, wantErr: true},
+		{name: "invalid json", config: `{`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewDatabaseCache().Start(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Start(%s) error = %v, want error %v", tt.config, err, tt.wantErr)
+			}
+		})
+	}
+	if !db.GetConnection(context.Background()).Migrator().HasTable("test_cache") {
+		t.Error("the table test_cache was not created")
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"encoding/gob"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -47,6 +49,9 @@ func (c *Cache) Get(ctx context.Context, key string) (interface{}, error) {
 		return nil, err
 	}
 	fileData, err := fileGetContents(fn)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, merror.Wrapf(cache.ErrKeyNotExist, "key: %s", key)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -60,6 +65,9 @@ func (c *Cache) Get(ctx context.Context, key string) (interface{}, error) {
 	if to.Expired.Before(time.Now()) {
 		return nil, cache.ErrKeyExpired
 	}
+	if err := cache.Negative(key, to.Data); err != nil {
+		return nil, err
+	}
 	return to.Data, nil
 }
 
@@ -85,7 +93,7 @@ func (c *Cache) GetMulti(ctx context.Context, keys []string) ([]interface{}, err
 func (c *Cache) Put(ctx context.Context, key string, val interface{}, timeout time.Duration) error {
 	gob.Register(val)
 
-	item := Item{Data: val}
+	item := Item{Key: key, Data: val}
 	if timeout == time.Duration(c.EmbedExpiry) {
 		item.Expired = time.Now().Add((86400 * 365 * 10) * time.Second) // ten years
 	} else {
@@ -119,6 +127,38 @@ func (c *Cache) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// Scan walks the cache directory and decodes the key stored in every cache file.
+// Files written before the key was stored in the item are skipped.
+func (c *Cache) Scan(ctx context.Context, pattern string, fn cache.ScanFunc) error {
+	return filepath.WalkDir(c.CachePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return merror.Wrapf(err, "could not walk the cache directory: %s", path)
+		}
+		if d.IsDir() || !strings.HasSuffix(path, c.FileSuffix) {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fileData, err := fileGetContents(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// deleted concurrently
+				return nil
+			}
+			return err
+		}
+		var idx indexItem
+		if err := gob.NewDecoder(bytes.NewBuffer(fileData)).Decode(&idx); err != nil {
+			return merror.Wrapf(err, "could not decode the cache file: %s", path)
+		}
+		if idx.Key == "" || idx.Expired.Before(time.Now()) || !cache.MatchPattern(pattern, idx.Key) {
+			return nil
+		}
+		return fn(idx.Key)
+	})
+}
+
 func (c *Cache) Start(config string) error {
 	cfg := make(map[string]string)
 	err := json.Unmarshal([]byte(config), &cfg)
@@ -0,0 +1,52 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/cache"
+)
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+	config, _ := json.Marshal(map[string]string{"CachePath": t.TempDir()})
+	c := NewFileCache().(*Cache)
+	if err := c.Start(string(config)); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		want    interface{}
+		wantErr error
+	}{
+		{name: "hit", key: "live", want: "value"},
+		{name: "miss", key: "missing", wantErr: cache.ErrKeyNotExist},
+		{name: "expired", key: "expired", wantErr: cache.ErrKeyExpired},
+		{name: "negative", key: "negative", wantErr: cache.ErrKeyNegative},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := newTestCache(t)
+			_ = c.Put(ctx, "live", "value", time.Minute)
+			_ = c.Put(ctx, "expired", "value", -time.Minute)
+			_ = cache.PutNegative(ctx, c, "negative", "not found", time.Minute)
+
+			got, err := c.Get(ctx, tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get(%q) error = %v, want %v", tt.key, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Get(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
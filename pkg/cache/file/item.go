@@ -3,7 +3,15 @@ package file
 import "time"
 
 type Item struct {
+	Key        string
 	Data       interface{}
 	LastAccess time.Time
 	Expired    time.Time
 }
+
+// indexItem is decoded from a cache file when only the key is needed,
+// gob skips the Data field so its type doesn't need to be registered.
+type indexItem struct {
+	Key     string
+	Expired time.Time
+}
@@ -0,0 +1,119 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/cache"
+	"github.com/go-monsters/monster/pkg/logger"
+
+	"go.elastic.co/apm/v2"
+)
+
+// DefaultMaxKeyLength is the key limit of memcache, the strictest of the built-in adapters.
+const DefaultMaxKeyLength = 250
+
+var ErrInvalidKey = merror.Error("the key is invalid")
+
+// Logging logs every call with its keys and elapsed time, failed calls are logged as errors.
+// A miss is a result, not a failure, it is logged as info.
+func Logging(l logger.Logger) cache.Interceptor {
+	return func(ctx context.Context, call *cache.Call, next cache.Invoker) (interface{}, error) {
+		start := time.Now()
+		res, err := next(ctx, call)
+		elapsed := time.Since(start)
+		keys := strings.Join(call.Keys, ",")
+		switch {
+		case err == nil:
+			l.Info("cache %s [%s] done in %s", call.Op, keys, elapsed)
+		case isMiss(err):
+			l.Info("cache %s [%s] missed in %s: %s", call.Op, keys, elapsed, err.Error())
+		default:
+			l.Error("cache %s [%s] failed after %s: %s", call.Op, keys, elapsed, err.Error())
+		}
+		return res, err
+	}
+}
+
+// Tracing reports every call as an Elastic APM span of the transaction found in ctx,
+// and its error unless it is a miss.
+func Tracing() cache.Interceptor {
+	return func(ctx context.Context, call *cache.Call, next cache.Invoker) (interface{}, error) {
+		span, ctx := apm.StartSpan(ctx, "cache."+call.Op, "cache")
+		defer span.End()
+		if !span.Dropped() {
+			span.Context.SetLabel("keys", strings.Join(call.Keys, ","))
+		}
+		res, err := next(ctx, call)
+		if err != nil && !isMiss(err) {
+			if e := apm.CaptureError(ctx, err); e != nil {
+				e.Send()
+			}
+		}
+		return res, err
+	}
+}
+
+// isMiss reports whether err only tells that the key holds no value.
+func isMiss(err error) bool {
+	return errors.Is(err, cache.ErrKeyNotExist) || errors.Is(err, cache.ErrKeyExpired) ||
+		errors.Is(err, cache.ErrKeyNegative)
+}
+
+// Recorder receives the measure of every call.
+type Recorder interface {
+	Observe(op string, keys int, elapsed time.Duration, err error)
+}
+
+// RecorderFunc is an adapter to use a function as a Recorder.
+type RecorderFunc func(op string, keys int, elapsed time.Duration, err error)
+
+func (f RecorderFunc) Observe(op string, keys int, elapsed time.Duration, err error) {
+	f(op, keys, elapsed, err)
+}
+
+// Metrics measures every call and hands it to r.
+func Metrics(r Recorder) cache.Interceptor {
+	return func(ctx context.Context, call *cache.Call, next cache.Invoker) (interface{}, error) {
+		start := time.Now()
+		res, err := next(ctx, call)
+		r.Observe(call.Op, len(call.Keys), time.Since(start), err)
+		return res, err
+	}
+}
+
+// KeyValidation rejects empty keys, keys longer than maxLength and keys containing
+// whitespace or control characters before they reach the adapter.
+// A maxLength lower than 1 means DefaultMaxKeyLength.
+func KeyValidation(maxLength int) cache.Interceptor {
+	if maxLength < 1 {
+		maxLength = DefaultMaxKeyLength
+	}
+	return func(ctx context.Context, call *cache.Call, next cache.Invoker) (interface{}, error) {
+		for _, key := range call.Keys {
+			if err := validateKey(key, maxLength); err != nil {
+				return nil, err
+			}
+		}
+		return next(ctx, call)
+	}
+}
+
+func validateKey(key string, maxLength int) error {
+	if key == "" {
+		return merror.Wrap(ErrInvalidKey, "the key is empty")
+	}
+	if len(key) > maxLength {
+		return merror.Wrapf(ErrInvalidKey, "the key is longer than %d bytes: %s", maxLength, key)
+	}
+	for _, r := range key {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return merror.Wrapf(ErrInvalidKey, "the key contains whitespace or control characters: %q", key)
+		}
+	}
+	return nil
+}
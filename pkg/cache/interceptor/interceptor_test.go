@@ -0,0 +1,147 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/cache"
+	"github.com/go-monsters/monster/pkg/cache/memory"
+)
+
+func TestIsMiss(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "not exist", err: cache.ErrKeyNotExist, want: true},
+		{name: "wrapped not exist", err: merror.Wrapf(cache.ErrKeyNotExist, "key: %s", "a"), want: true},
+		{name: "expired", err: cache.ErrKeyExpired, want: true},
+		{name: "negative", err: &cache.NegativeError{Key: "a"}, want: true},
+		{name: "other", err: errors.New("connection refused"), want: false},
+		{name: "wrapped other", err: merror.Wrap(errors.New("connection refused"), "error with get"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isMiss(tt.err); got != tt.want {
+				t.Errorf("isMiss(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingLogger keeps the level of every message.
+type recordingLogger struct {
+	levels []string
+}
+
+func (l *recordingLogger) Info(msg string, params ...interface{}) {
+	l.levels = append(l.levels, "info")
+}
+
+func (l *recordingLogger) Warn(msg string, params ...interface{}) {
+	l.levels = append(l.levels, "warn")
+}
+
+func (l *recordingLogger) Error(msg string, params ...interface{}) {
+	l.levels = append(l.levels, "error")
+}
+
+func newMemoryCache(t *testing.T) cache.Cache {
+	t.Helper()
+	c := memory.NewMemoryCache()
+	if err := c.Start(`{"interval": 0}`); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLogging(t *testing.T) {
+	errDown := errors.New("down")
+	tests := []struct {
+		name  string
+		key   string
+		inner cache.Interceptor // nil reaches the memory cache
+		want  string
+	}{
+		{name: "hit", key: "a", want: "info"},
+		{name: "miss", key: "missing", want: "info"},
+		{name: "negative", key: "negative", want: "info"},
+		{
+			name: "failure",
+			key:  "a",
+			inner: func(ctx context.Context, call *cache.Call, next cache.Invoker) (interface{}, error) {
+				return nil, errDown
+			},
+			want: "error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := newMemoryCache(t)
+			_ = c.Put(ctx, "a", "A", time.Minute)
+			_ = cache.PutNegative(ctx, c, "negative", "not found", time.Minute)
+
+			l := &recordingLogger{}
+			interceptors := []cache.Interceptor{Logging(l)}
+			if tt.inner != nil {
+				interceptors = append(interceptors, tt.inner)
+			}
+			_, _ = cache.Wrap(c, interceptors...).Get(ctx, tt.key)
+			if strings.Join(l.levels, ",") != tt.want {
+				t.Errorf("logged %v, want [%s]", l.levels, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	var got []string
+	c := cache.Wrap(newMemoryCache(t), Metrics(RecorderFunc(func(op string, keys int, elapsed time.Duration, err error) {
+		got = append(got, fmt.Sprintf("%s:%d:%v", op, keys, err == nil))
+	})))
+	_ = c.Put(ctx, "a", "A", time.Minute)
+	_, _ = c.GetMulti(ctx, []string{"a", "b"})
+	_ = c.Delete(ctx, "a")
+
+	want := "Put:1:true GetMulti:2:false Delete:1:true"
+	if strings.Join(got, " ") != want {
+		t.Errorf("observed %v, want %s", got, want)
+	}
+}
+
+func TestKeyValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxLength int
+		key       string
+		wantErr   bool
+	}{
+		{name: "valid", key: "user:42"},
+		{name: "unicode", key: "user:é"},
+		{name: "empty", key: "", wantErr: true},
+		{name: "space", key: "user 42", wantErr: true},
+		{name: "newline", key: "user:42\n", wantErr: true},
+		{name: "control", key: "user:\x00", wantErr: true},
+		{name: "at the limit", maxLength: 4, key: "abcd"},
+		{name: "over the limit", maxLength: 4, key: "abcde", wantErr: true},
+		{name: "over the default limit", key: strings.Repeat("a", DefaultMaxKeyLength+1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := cache.Wrap(newMemoryCache(t), KeyValidation(tt.maxLength))
+			err := c.Put(ctx, tt.key, "value", time.Minute)
+			if errors.Is(err, ErrInvalidKey) != tt.wantErr {
+				t.Errorf("Put(%q) error = %v, want invalid key %v", tt.key, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package memcache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -28,7 +29,12 @@ func (c *Cache) GetClient() interface{} {
 
 func (c *Cache) Get(ctx context.Context, key string) (interface{}, error) {
 	if item, err := c.conn.Get(key); err == nil {
+		if err := cache.Negative(key, item.Value); err != nil {
+			return nil, err
+		}
 		return item.Value, nil
+	} else if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil, merror.Wrapf(cache.ErrKeyNotExist, "key: %s", key)
 	} else {
 		return nil, merror.Wrapf(err,
 			"could not read data from memcache, please check your key, network and connection. Root cause: %s",
@@ -53,6 +59,10 @@ func (c *Cache) GetMulti(ctx context.Context, keys []string) ([]interface{}, err
 			keysErr = append(keysErr, fmt.Sprintf("key [%s] error: %s", ki, "key not exist"))
 			continue
 		}
+		if err := cache.Negative(ki, mv[ki].Value); err != nil {
+			keysErr = append(keysErr, fmt.Sprintf("key [%s] error: %s", ki, err.Error()))
+			continue
+		}
 		rv[i] = mv[ki].Value
 	}
 
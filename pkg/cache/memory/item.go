@@ -1,11 +1,42 @@
 package memory
 
-import "time"
+import (
+	"sync/atomic"
+	"time"
+)
 
 type Item struct {
 	val         interface{}
 	createdTime time.Time
 	lifespan    time.Duration
+	sliding     bool  // the lifespan starts again on every read
+	accessed    int64 // unix nano of the last read of a sliding item, accessed atomically
+}
+
+func newItem(val interface{}, lifespan time.Duration, sliding bool) *Item {
+	now := time.Now()
+	return &Item{
+		val:         val,
+		createdTime: now,
+		lifespan:    lifespan,
+		sliding:     sliding,
+		accessed:    now.UnixNano(),
+	}
+}
+
+// touch extends the lifetime of a sliding item, it is called under the read lock.
+func (mi *Item) touch() {
+	if mi.sliding {
+		atomic.StoreInt64(&mi.accessed, time.Now().UnixNano())
+	}
+}
+
+// age is the time elapsed since the item was created, or since it was last read if it is sliding.
+func (mi *Item) age() time.Duration {
+	if mi.sliding {
+		return time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&mi.accessed))
+	}
+	return time.Since(mi.createdTime)
 }
 
 func (mi *Item) isExpire() bool {
@@ -13,5 +44,5 @@ func (mi *Item) isExpire() bool {
 	if mi.lifespan == 0 {
 		return false
 	}
-	return time.Since(mi.createdTime) > mi.lifespan
+	return mi.age() > mi.lifespan
 }
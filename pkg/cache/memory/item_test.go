@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/cache"
+)
+
+func TestIsExpire(t *testing.T) {
+	tests := []struct {
+		name     string
+		lifespan time.Duration
+		sliding  bool
+		created  time.Duration // time since the creation
+		accessed time.Duration // time since the last read
+		want     bool
+	}{
+		{name: "forever", lifespan: 0, created: 24 * time.Hour, accessed: 24 * time.Hour},
+		{name: "fixed live", lifespan: time.Hour, created: 30 * time.Minute, accessed: 30 * time.Minute},
+		{name: "fixed expired", lifespan: time.Hour, created: 2 * time.Hour, accessed: time.Minute, want: true},
+		{name: "sliding read recently", lifespan: time.Hour, sliding: true, created: 2 * time.Hour, accessed: time.Minute},
+		{name: "sliding not read", lifespan: time.Hour, sliding: true, created: 2 * time.Hour, accessed: 2 * time.Hour, want: true},
+		{name: "negative lifespan", lifespan: -time.Minute, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			itm := &Item{
+				lifespan:    tt.lifespan,
+				sliding:     tt.sliding,
+				createdTime: now.Add(-tt.created),
+				accessed:    now.Add(-tt.accessed).UnixNano(),
+			}
+			if got := itm.isExpire(); got != tt.want {
+				t.Errorf("isExpire() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlidingGet(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache().(*Cache)
+	_ = c.PutSliding(ctx, "sliding", "value", time.Hour)
+	_ = c.Put(ctx, "fixed", "value", time.Hour)
+	for _, key := range []string{"sliding", "fixed"} {
+		itm := c.shard(key).items[key]
+		itm.createdTime = time.Now().Add(-50 * time.Minute)
+		itm.accessed = itm.createdTime.UnixNano()
+	}
+
+	// a read starts the lifespan of a sliding item again, not the one of a fixed item
+	for _, key := range []string{"sliding", "fixed"} {
+		if _, err := c.Get(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"sliding", "fixed"} {
+		itm := c.shard(key).items[key]
+		itm.createdTime = itm.createdTime.Add(-20 * time.Minute)
+		itm.accessed -= int64(20 * time.Minute)
+	}
+
+	if _, err := c.Get(ctx, "sliding"); err != nil {
+		t.Errorf("Get(sliding) error = %v, want the value read 20m ago", err)
+	}
+	if _, err := c.Get(ctx, "fixed"); !errors.Is(err, cache.ErrKeyExpired) {
+		t.Errorf("Get(fixed) error = %v, want %v", err, cache.ErrKeyExpired)
+	}
+}
@@ -12,17 +12,30 @@ import (
 	"github.com/go-monsters/monster/pkg/cache"
 )
 
-var DefaultEvery = 60 // 1 minute
+var (
+	DefaultEvery = 60 // 1 minute
+	// DefaultVacuumBatch is the number of keys the expiration check reads and deletes per lock of a shard.
+	DefaultVacuumBatch = 1000
+)
+
+// EvictionFunc receives an item removed from the cache.
+type EvictionFunc func(key string, val interface{})
 
 type Cache struct {
 	sync.RWMutex
-	dur   time.Duration
-	items map[string]*Item
-	Every int // run an expiration check Every clock time
+	dur    time.Duration
+	shards []*shard
+	Every  int // run an expiration check Every clock time
+
+	snapshotPath string
+	snapshotDone chan struct{}
+
+	onExpired EvictionFunc
+	onDeleted EvictionFunc
 }
 
 func NewMemoryCache() cache.Cache {
-	return &Cache{items: make(map[string]*Item)}
+	return &Cache{shards: newShards(1)}
 }
 
 func (c *Cache) GetClient() interface{} {
@@ -30,12 +43,17 @@ func (c *Cache) GetClient() interface{} {
 }
 
 func (c *Cache) Get(ctx context.Context, key string) (interface{}, error) {
-	c.RLock()
-	defer c.RUnlock()
-	if itm, ok := c.items[key]; ok {
+	s := c.shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	if itm, ok := s.items[key]; ok {
 		if itm.isExpire() {
 			return nil, cache.ErrKeyExpired
 		}
+		if err := cache.Negative(key, itm.val); err != nil {
+			return nil, err
+		}
+		itm.touch()
 		return itm.val, nil
 	}
 	return nil, cache.ErrKeyNotExist
@@ -61,35 +79,106 @@ func (c *Cache) GetMulti(ctx context.Context, keys []string) ([]interface{}, err
 }
 
 func (c *Cache) Put(ctx context.Context, key string, val interface{}, timeout time.Duration) error {
-	c.Lock()
-	defer c.Unlock()
-	c.items[key] = &Item{
-		val:         val,
-		createdTime: time.Now(),
-		lifespan:    timeout,
-	}
+	c.put(key, newItem(val, timeout, false))
+	return nil
+}
+
+// PutSliding puts a key-value whose timeout starts again every time the key is read,
+// so the item only expires after it hasn't been read for the timeout.
+func (c *Cache) PutSliding(ctx context.Context, key string, val interface{}, timeout time.Duration) error {
+	c.put(key, newItem(val, timeout, true))
 	return nil
 }
 
 func (c *Cache) Delete(ctx context.Context, key string) error {
+	s := c.shard(key)
+	s.Lock()
+	itm, ok := s.items[key]
+	delete(s.items, key)
+	s.Unlock()
+
+	if ok {
+		c.RLock()
+		onDeleted := c.onDeleted
+		c.RUnlock()
+		if onDeleted != nil {
+			onDeleted(key, itm.val)
+		}
+	}
+	return nil
+}
+
+// OnExpired sets the function called for every expired item removed by the expiration check or replaced by a put.
+func (c *Cache) OnExpired(fn EvictionFunc) {
 	c.Lock()
 	defer c.Unlock()
-	delete(c.items, key)
+	c.onExpired = fn
+}
+
+// OnDeleted sets the function called for every item removed by Delete or replaced by a put before it expired.
+func (c *Cache) OnDeleted(fn EvictionFunc) {
+	c.Lock()
+	defer c.Unlock()
+	c.onDeleted = fn
+}
+
+func (c *Cache) Scan(ctx context.Context, pattern string, fn cache.ScanFunc) error {
+	// collect the keys first so that fn is free to modify the cache,
+	// they are matched out of the locks
+	keys := make([]string, 0)
+	for _, s := range c.shards {
+		s.RLock()
+		for key, itm := range s.items {
+			if !itm.isExpire() {
+				keys = append(keys, key)
+			}
+		}
+		s.RUnlock()
+	}
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !cache.MatchPattern(pattern, key) {
+			continue
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Start reads the config {"interval": 60, "shards": 1, "snapshot": "cache/memory.snapshot", "snapshotInterval": 300},
+// the fields are optional. With more than one shard, the items are split between shards
+// having their own lock, which reduces the contention under heavy concurrent writes.
+// The snapshot fields are described by Snapshot.
 func (c *Cache) Start(config string) error {
-	var cf map[string]int
+	var cf map[string]interface{}
 	if err := json.Unmarshal([]byte(config), &cf); err != nil {
 		return merror.Wrapf(err, "invalid config, please check your input: %s", config)
 	}
-	if _, ok := cf["interval"]; !ok {
-		cf = make(map[string]int)
-		cf["interval"] = DefaultEvery
+	every := DefaultEvery
+	if interval, ok := cf["interval"].(float64); ok {
+		every = int(interval)
 	}
-	dur := time.Duration(cf["interval"]) * time.Second
-	c.Every = cf["interval"]
+	dur := time.Duration(every) * time.Second
+	c.Every = every
 	c.dur = dur
+	if shards, ok := cf["shards"].(float64); ok && int(shards) > 1 {
+		c.shards = newShards(int(shards))
+	}
+
+	if path, ok := cf["snapshot"].(string); ok && path != "" {
+		snapshotEvery := DefaultSnapshotEvery
+		if interval, ok := cf["snapshotInterval"].(float64); ok {
+			snapshotEvery = int(interval)
+		}
+		if err := c.startSnapshot(path, snapshotEvery); err != nil {
+			return err
+		}
+	}
 	go c.vacuum()
 	return nil
 }
@@ -104,35 +193,67 @@ func (c *Cache) vacuum() {
 	}
 	for {
 		<-time.After(c.dur)
-		c.RLock()
-		if c.items == nil {
-			c.RUnlock()
+		if c.shards == nil {
 			return
 		}
-		c.RUnlock()
-		if keys := c.expiredKeys(); len(keys) != 0 {
-			c.clearItems(keys)
+		for _, s := range c.shards {
+			c.vacuumShard(s, DefaultVacuumBatch)
 		}
 	}
 }
 
-func (c *Cache) expiredKeys() (keys []string) {
-	c.RLock()
-	defer c.RUnlock()
-	for key, itm := range c.items {
-		if itm.isExpire() {
-			keys = append(keys, key)
+// vacuumShard removes the expired items of the shard, walking a copy of its keys by batches so that
+// a large cache doesn't block the writers for a whole scan. Every item is checked once per call,
+// the items added meanwhile are checked by the next one.
+func (c *Cache) vacuumShard(s *shard, batch int) {
+	keys := s.keys()
+	for len(keys) > 0 {
+		n := batch
+		if n < 1 || n > len(keys) {
+			n = len(keys)
 		}
+		if expired := s.expiredKeys(keys[:n]); len(expired) > 0 {
+			removed := s.clearItems(expired)
+			c.RLock()
+			onExpired := c.onExpired
+			c.RUnlock()
+			if onExpired != nil {
+				for key, itm := range removed {
+					onExpired(key, itm.val)
+				}
+			}
+		}
+		keys = keys[n:]
 	}
-	return
 }
 
-func (c *Cache) clearItems(keys []string) {
-	c.Lock()
-	defer c.Unlock()
-	for _, key := range keys {
-		delete(c.items, key)
+// put stores the item, the item it replaces is given to OnExpired if it expired, to OnDeleted otherwise.
+func (c *Cache) put(key string, itm *Item) {
+	s := c.shard(key)
+	s.Lock()
+	replaced, ok := s.items[key]
+	s.items[key] = itm
+	s.Unlock()
+	if !ok {
+		return
+	}
+
+	c.RLock()
+	evicted := c.onDeleted
+	if replaced.isExpire() {
+		evicted = c.onExpired
+	}
+	c.RUnlock()
+	if evicted != nil {
+		evicted(key, replaced.val)
+	}
+}
+
+func (c *Cache) shard(key string) *shard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
 	}
+	return c.shards[fnv32a(key)%uint32(len(c.shards))]
 }
 
 func init() {
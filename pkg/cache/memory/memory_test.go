@@ -0,0 +1,342 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/cache"
+)
+
+const benchKeys = 1 << 16
+
+var benchShards = []int{1, 16, 64}
+
+type benchCache interface {
+	Get(ctx context.Context, key string) (interface{}, error)
+	Put(ctx context.Context, key string, val interface{}, timeout time.Duration) error
+}
+
+// globalCache is the memory cache as it was before the shards, a map behind a single lock.
+// It is the baseline of the benchmarks.
+type globalCache struct {
+	sync.RWMutex
+	items map[string]*Item
+}
+
+func (c *globalCache) Get(ctx context.Context, key string) (interface{}, error) {
+	c.RLock()
+	defer c.RUnlock()
+	if itm, ok := c.items[key]; ok {
+		if itm.isExpire() {
+			return nil, cache.ErrKeyExpired
+		}
+		return itm.val, nil
+	}
+	return nil, cache.ErrKeyNotExist
+}
+
+func (c *globalCache) Put(ctx context.Context, key string, val interface{}, timeout time.Duration) error {
+	c.Lock()
+	defer c.Unlock()
+	c.items[key] = &Item{val: val, createdTime: time.Now(), lifespan: timeout}
+	return nil
+}
+
+// runBenchCaches runs fn on the baseline, then on the memory cache with every count of benchShards,
+// each one filled with benchKeys keys.
+func runBenchCaches(b *testing.B, fn func(b *testing.B, c benchCache)) {
+	names := []string{"global"}
+	caches := []benchCache{&globalCache{items: make(map[string]*Item)}}
+	for _, shards := range benchShards {
+		c := NewMemoryCache().(*Cache)
+		// interval 0 disables the expiration check, it would skew the results
+		if err := c.Start(fmt.Sprintf(`{"interval": 0, "shards": %d}`, shards)); err != nil {
+			b.Fatal(err)
+		}
+		names = append(names, fmt.Sprintf("shards=%d", shards))
+		caches = append(caches, c)
+	}
+
+	ctx := context.Background()
+	for i, c := range caches {
+		for k := 0; k < benchKeys; k++ {
+			_ = c.Put(ctx, strconv.Itoa(k), k, time.Hour)
+		}
+		b.Run(names[i], func(b *testing.B) { fn(b, c) })
+	}
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+	runBenchCaches(b, func(b *testing.B, c benchCache) {
+		ctx := context.Background()
+		var seq uint64
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := atomic.AddUint64(&seq, 1) * 7919
+			for pb.Next() {
+				i++
+				_, _ = c.Get(ctx, strconv.Itoa(int(i%benchKeys)))
+			}
+		})
+	})
+}
+
+func BenchmarkPutParallel(b *testing.B) {
+	runBenchCaches(b, func(b *testing.B, c benchCache) {
+		ctx := context.Background()
+		var seq uint64
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := atomic.AddUint64(&seq, 1) * 7919
+			for pb.Next() {
+				i++
+				_ = c.Put(ctx, strconv.Itoa(int(i%benchKeys)), i, time.Hour)
+			}
+		})
+	})
+}
+
+// BenchmarkMixedParallel reads 9 times out of 10 and writes otherwise.
+func BenchmarkMixedParallel(b *testing.B) {
+	runBenchCaches(b, func(b *testing.B, c benchCache) {
+		ctx := context.Background()
+		var seq uint64
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := atomic.AddUint64(&seq, 1) * 7919
+			for pb.Next() {
+				i++
+				key := strconv.Itoa(int(i % benchKeys))
+				if i%10 == 0 {
+					_ = c.Put(ctx, key, i, time.Hour)
+				} else {
+					_, _ = c.Get(ctx, key)
+				}
+			}
+		})
+	})
+}
+
+func TestVacuumShard(t *testing.T) {
+	tests := []struct {
+		name    string
+		expired int
+		live    int
+		batch   int
+	}{
+		{name: "nothing expired", live: 100, batch: 10},
+		{name: "everything expired", expired: 100, batch: 10},
+		{name: "few expired among many", expired: 3, live: 5000, batch: 100},
+		{name: "batch larger than the shard", expired: 50, live: 50, batch: 1000},
+		{name: "no batch", expired: 50, live: 50, batch: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemoryCache().(*Cache)
+			var fired int
+			c.OnExpired(func(key string, val interface{}) { fired++ })
+			s := c.shards[0]
+			for i := 0; i < tt.expired; i++ {
+				s.items["expired"+strconv.Itoa(i)] = &Item{createdTime: time.Now().Add(-time.Hour), lifespan: time.Second}
+			}
+			for i := 0; i < tt.live; i++ {
+				s.items["live"+strconv.Itoa(i)] = &Item{createdTime: time.Now(), lifespan: time.Hour}
+			}
+
+			c.vacuumShard(s, tt.batch)
+
+			if len(s.items) != tt.live {
+				t.Errorf("items left = %d, want %d", len(s.items), tt.live)
+			}
+			if fired != tt.expired {
+				t.Errorf("OnExpired called %d times, want %d", fired, tt.expired)
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+	}{
+		{name: "every live key", pattern: "*", want: []string{"order:1", "user:1", "user:2"}},
+		{name: "matching keys", pattern: "user:*", want: []string{"user:1", "user:2"}},
+		{name: "expired key", pattern: "user:old", want: nil},
+		{name: "no match", pattern: "item:*", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := NewMemoryCache().(*Cache)
+			for _, key := range []string{"user:1", "user:2", "order:1"} {
+				_ = c.Put(ctx, key, key, time.Hour)
+			}
+			c.shard("user:old").items["user:old"] = &Item{createdTime: time.Now().Add(-time.Hour), lifespan: time.Second}
+
+			var got []string
+			// fn is free to write to the cache
+			err := cache.Scan(ctx, c, tt.pattern, func(key string) error {
+				got = append(got, key)
+				return c.Delete(ctx, key)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Scan keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShards(t *testing.T) {
+	tests := []struct {
+		config string
+		want   int
+	}{
+		{config: `{"interval": 0}`, want: 1},
+		{config: `{"interval": 0, "shards": 0}`, want: 1},
+		{config: `{"interval": 0, "shards": 1}`, want: 1},
+		{config: `{"interval": 0, "shards": 16}`, want: 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.config, func(t *testing.T) {
+			ctx := context.Background()
+			c := NewMemoryCache().(*Cache)
+			if err := c.Start(tt.config); err != nil {
+				t.Fatal(err)
+			}
+			if len(c.shards) != tt.want {
+				t.Fatalf("%d shards, want %d", len(c.shards), tt.want)
+			}
+
+			for i := 0; i < 1000; i++ {
+				_ = c.Put(ctx, strconv.Itoa(i), i, time.Minute)
+			}
+			total := 0
+			for _, s := range c.shards {
+				if tt.want > 1 && len(s.items) == 0 {
+					t.Error("a shard holds no key")
+				}
+				total += len(s.items)
+			}
+			if total != 1000 {
+				t.Errorf("%d items in the shards, want 1000", total)
+			}
+			for i := 0; i < 1000; i++ {
+				if got, err := c.Get(ctx, strconv.Itoa(i)); err != nil || got != i {
+					t.Fatalf("Get(%d) = %v, %v", i, got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFnv32a(t *testing.T) {
+	for _, key := range []string{"", "a", "user:42", "héllo"} {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key))
+		if got, want := fnv32a(key), h.Sum32(); got != want {
+			t.Errorf("fnv32a(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache().(*Cache)
+	if err := c.Start(`{"interval": 0, "shards": 8}`); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := strconv.Itoa(i % 100)
+				switch i % 4 {
+				case 0:
+					_ = c.Put(ctx, key, w, time.Minute)
+				case 1:
+					_ = c.Delete(ctx, key)
+				case 2:
+					c.vacuumShard(c.shard(key), 10)
+				default:
+					_, _ = c.Get(ctx, key)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestEvictionCallbacks(t *testing.T) {
+	tests := []struct {
+		name        string
+		evict       func(ctx context.Context, c *Cache)
+		wantExpired []string
+		wantDeleted []string
+	}{
+		{
+			name:        "delete",
+			evict:       func(ctx context.Context, c *Cache) { _ = c.Delete(ctx, "live") },
+			wantDeleted: []string{"live=old"},
+		},
+		{
+			name:  "delete missing",
+			evict: func(ctx context.Context, c *Cache) { _ = c.Delete(ctx, "missing") },
+		},
+		{
+			name:        "put over a live item",
+			evict:       func(ctx context.Context, c *Cache) { _ = c.Put(ctx, "live", "new", time.Minute) },
+			wantDeleted: []string{"live=old"},
+		},
+		{
+			name:        "put over an expired item",
+			evict:       func(ctx context.Context, c *Cache) { _ = c.PutSliding(ctx, "expired", "new", time.Minute) },
+			wantExpired: []string{"expired=old"},
+		},
+		{
+			name:  "put a new key",
+			evict: func(ctx context.Context, c *Cache) { _ = c.Put(ctx, "new", "new", time.Minute) },
+		},
+		{
+			name:        "vacuum",
+			evict:       func(ctx context.Context, c *Cache) { c.vacuumShard(c.shards[0], DefaultVacuumBatch) },
+			wantExpired: []string{"expired=old"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := NewMemoryCache().(*Cache)
+			_ = c.Put(ctx, "live", "old", time.Minute)
+			c.shards[0].items["expired"] = &Item{val: "old", createdTime: time.Now().Add(-time.Hour), lifespan: time.Second}
+
+			var expired, deleted []string
+			c.OnExpired(func(key string, val interface{}) { expired = append(expired, fmt.Sprintf("%s=%v", key, val)) })
+			c.OnDeleted(func(key string, val interface{}) { deleted = append(deleted, fmt.Sprintf("%s=%v", key, val)) })
+
+			tt.evict(ctx, c)
+
+			if strings.Join(expired, ",") != strings.Join(tt.wantExpired, ",") {
+				t.Errorf("OnExpired got %v, want %v", expired, tt.wantExpired)
+			}
+			if strings.Join(deleted, ",") != strings.Join(tt.wantDeleted, ",") {
+				t.Errorf("OnDeleted got %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package memory
+
+import "sync"
+
+// shard is a part of the items with its own lock, a key always lives in the same shard.
+type shard struct {
+	sync.RWMutex
+	items map[string]*Item
+}
+
+func newShards(n int) []*shard {
+	if n < 1 {
+		n = 1
+	}
+	shards := make([]*shard, n)
+	for i := range shards {
+		shards[i] = &shard{items: make(map[string]*Item)}
+	}
+	return shards
+}
+
+// keys returns the keys of the shard at the time of the call.
+func (s *shard) keys() []string {
+	s.RLock()
+	defer s.RUnlock()
+	keys := make([]string, 0, len(s.items))
+	for key := range s.items {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// expiredKeys returns the keys among keys whose item is expired, holding the read lock only meanwhile.
+func (s *shard) expiredKeys(keys []string) (expired []string) {
+	s.RLock()
+	defer s.RUnlock()
+	for _, key := range keys {
+		if itm, ok := s.items[key]; ok && itm.isExpire() {
+			expired = append(expired, key)
+		}
+	}
+	return
+}
+
+// clearItems removes the keys which are still expired, a sliding item may have been read since the scan.
+func (s *shard) clearItems(keys []string) map[string]*Item {
+	s.Lock()
+	defer s.Unlock()
+	removed := make(map[string]*Item, len(keys))
+	for _, key := range keys {
+		if itm, ok := s.items[key]; ok && itm.isExpire() {
+			delete(s.items, key)
+			removed[key] = itm
+		}
+	}
+	return removed
+}
+
+// fnv32a hashes the key without allocating, see hash/fnv.
+func fnv32a(key string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	h := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= prime32
+	}
+	return h
+}
@@ -0,0 +1,161 @@
+package memory
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+)
+
+var DefaultSnapshotEvery = 300 // 5 minutes
+
+// snapshotItem is the persisted form of an Item, Elapsed is the age of the item when the snapshot was taken.
+type snapshotItem struct {
+	Key      string
+	Val      interface{}
+	Elapsed  time.Duration
+	Lifespan time.Duration
+	Sliding  bool
+}
+
+// Snapshot writes the items which are not expired to w, encoded by gob.
+// The concrete types of the values are registered to gob, the process calling Restore
+// must register them as well (gob.Register) before it is able to decode them.
+func (c *Cache) Snapshot(w io.Writer) error {
+	items := make([]snapshotItem, 0)
+	for _, s := range c.shards {
+		s.RLock()
+		for key, itm := range s.items {
+			if itm.isExpire() {
+				continue
+			}
+			items = append(items, snapshotItem{
+				Key:      key,
+				Val:      itm.val,
+				Elapsed:  itm.age(),
+				Lifespan: itm.lifespan,
+				Sliding:  itm.sliding,
+			})
+		}
+		s.RUnlock()
+	}
+
+	for _, itm := range items {
+		if itm.Val != nil {
+			gob.Register(itm.Val)
+		}
+	}
+	if err := gob.NewEncoder(w).Encode(items); err != nil {
+		return merror.Wrap(err, "could not encode the memory cache snapshot")
+	}
+	return nil
+}
+
+// Restore reads a snapshot written by Snapshot and puts its items into the cache,
+// keeping their remaining lifespan. Items expired in the meantime are dropped.
+func (c *Cache) Restore(r io.Reader) error {
+	var items []snapshotItem
+	if err := gob.NewDecoder(r).Decode(&items); err != nil {
+		return merror.Wrap(err,
+			"could not decode the memory cache snapshot. Make sure that the types of the values are registered to GOB.")
+	}
+
+	now := time.Now()
+	for _, si := range items {
+		createdTime := now.Add(-si.Elapsed)
+		itm := &Item{
+			val:         si.Val,
+			createdTime: createdTime,
+			lifespan:    si.Lifespan,
+			sliding:     si.Sliding,
+			accessed:    createdTime.UnixNano(),
+		}
+		if itm.isExpire() {
+			continue
+		}
+		c.put(si.Key, itm)
+	}
+	return nil
+}
+
+// SnapshotFile writes the snapshot to path, the previous snapshot is replaced only when the new one is complete.
+func (c *Cache) SnapshotFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return merror.Wrapf(err, "could not create the snapshot directory of: %s", path)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return merror.Wrapf(err, "could not create the snapshot file: %s", path)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := c.Snapshot(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return merror.Wrapf(err, "could not write the snapshot file: %s", path)
+	}
+	return merror.Wrapf(os.Rename(tmp.Name(), path), "could not replace the snapshot file: %s", path)
+}
+
+// RestoreFile restores the snapshot found at path, a missing file is not an error.
+func (c *Cache) RestoreFile(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return merror.Wrapf(err, "could not open the snapshot file: %s", path)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return c.Restore(f)
+}
+
+// Close writes the last snapshot if a snapshot path is configured and stops the periodic snapshots.
+func (c *Cache) Close() error {
+	c.Lock()
+	path, done := c.snapshotPath, c.snapshotDone
+	c.snapshotPath, c.snapshotDone = "", nil
+	c.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	close(done)
+	return c.SnapshotFile(path)
+}
+
+func (c *Cache) startSnapshot(path string, every int) error {
+	if err := c.RestoreFile(path); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	c.Lock()
+	c.snapshotPath, c.snapshotDone = path, done
+	c.Unlock()
+
+	if every < 1 {
+		return nil
+	}
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Duration(every) * time.Second):
+			}
+			_ = c.SnapshotFile(path)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/cache"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	tests := []struct {
+		name    string
+		put     func(c *Cache)
+		key     string
+		want    interface{}
+		wantErr error
+	}{
+		{
+			name: "string",
+			put:  func(c *Cache) { _ = c.Put(context.Background(), "k", "value", time.Minute) },
+			key:  "k",
+			want: "value",
+		},
+		{
+			name: "struct",
+			put:  func(c *Cache) { _ = c.Put(context.Background(), "k", point{X: 1, Y: 2}, time.Minute) },
+			key:  "k",
+			want: point{X: 1, Y: 2},
+		},
+		{
+			name: "forever",
+			put:  func(c *Cache) { _ = c.Put(context.Background(), "k", 42, 0) },
+			key:  "k",
+			want: 42,
+		},
+		{
+			name: "negative",
+			put: func(c *Cache) {
+				_ = cache.PutNegative(context.Background(), c, "k", "not found", time.Minute)
+			},
+			key:     "k",
+			wantErr: cache.ErrKeyNegative,
+		},
+		{
+			name: "expired",
+			put: func(c *Cache) {
+				c.shard("k").items["k"] = &Item{val: "value", createdTime: time.Now().Add(-time.Hour), lifespan: time.Second}
+			},
+			key:     "k",
+			wantErr: cache.ErrKeyNotExist,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := NewMemoryCache().(*Cache)
+			tt.put(from)
+			var buf bytes.Buffer
+			if err := from.Snapshot(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			to := NewMemoryCache().(*Cache)
+			if err := to.Restore(&buf); err != nil {
+				t.Fatal(err)
+			}
+			got, err := to.Get(context.Background(), tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreLifespan(t *testing.T) {
+	from := NewMemoryCache().(*Cache)
+	from.shard("fixed").items["fixed"] = newItem("value", time.Hour, false)
+	from.shard("fixed").items["fixed"].createdTime = time.Now().Add(-40 * time.Minute)
+	_ = from.PutSliding(context.Background(), "sliding", "value", time.Hour)
+
+	var buf bytes.Buffer
+	if err := from.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	to := NewMemoryCache().(*Cache)
+	if err := to.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	fixed := to.shard("fixed").items["fixed"]
+	if age := fixed.age(); age < 40*time.Minute || age > 41*time.Minute {
+		t.Errorf("restored age = %s, want the 40m elapsed before the snapshot", age)
+	}
+	if sliding := to.shard("sliding").items["sliding"]; !sliding.sliding || sliding.lifespan != time.Hour {
+		t.Errorf("restored sliding item = %+v, want sliding for 1h", sliding)
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory", "snapshot")
+	ctx := context.Background()
+
+	c := NewMemoryCache().(*Cache)
+	if err := c.RestoreFile(path); err != nil {
+		t.Fatalf("RestoreFile of a missing file error = %v", err)
+	}
+	_ = c.Put(ctx, "k", "value", time.Minute)
+	if err := c.SnapshotFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Start restores the snapshot and Close writes the last one
+	config, _ := json.Marshal(map[string]interface{}{"interval": 0, "snapshot": path, "snapshotInterval": 0})
+	restored := NewMemoryCache().(*Cache)
+	if err := restored.Start(string(config)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := restored.Get(ctx, "k"); err != nil || got != "value" {
+		t.Fatalf("Get after Start = %v, %v, want value", got, err)
+	}
+	_ = restored.Put(ctx, "k2", "value2", time.Minute)
+	if err := restored.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	last := NewMemoryCache().(*Cache)
+	if err := last.RestoreFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := last.Get(ctx, "k2"); err != nil || got != "value2" {
+		t.Errorf("Get after Close = %v, %v, want value2", got, err)
+	}
+}
@@ -0,0 +1,199 @@
+package migrating
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/cache"
+)
+
+// Stats is the progress of a migration, a growing share of NewHits means the new adapter is warming up.
+type Stats struct {
+	NewHits     uint64 // reads served by the new adapter
+	OldHits     uint64 // reads served by the old adapter after a miss of the new one
+	Misses      uint64 // reads missed by both adapters
+	Backfills   uint64 // values copied from the old adapter to the new one
+	WriteErrors uint64 // writes or deletes failed on the old adapter, returned if it still holds the key
+}
+
+type backend struct {
+	Impl   string          `json:"impl"`
+	Config json.RawMessage `json:"config"`
+}
+
+type options struct {
+	Old      backend `json:"old"`
+	New      backend `json:"new"`
+	Backfill int     `json:"backfill"` // timeout in seconds of the values copied to the new adapter, 0 disables it
+}
+
+// Cache writes to an old and a new adapter and reads from the new one with fallback to the old one,
+// so an application moves between two backends without starting with a cold cache.
+type Cache struct {
+	old      cache.Cache
+	new      cache.Cache
+	backfill time.Duration
+
+	newHits     uint64
+	oldHits     uint64
+	misses      uint64
+	backfills   uint64
+	writeErrors uint64
+}
+
+func NewMigratingCache() cache.Cache {
+	return &Cache{}
+}
+
+// New returns a migrating cache over two started adapters, Start must not be called.
+func New(from, to cache.Cache, backfill time.Duration) *Cache {
+	return &Cache{old: from, new: to, backfill: backfill}
+}
+
+func (c *Cache) GetClient() interface{} {
+	return c.new.GetClient()
+}
+
+// Old returns the adapter migrated from.
+func (c *Cache) Old() cache.Cache {
+	return c.old
+}
+
+// New returns the adapter migrated to.
+func (c *Cache) New() cache.Cache {
+	return c.new
+}
+
+func (c *Cache) Stats() Stats {
+	return Stats{
+		NewHits:     atomic.LoadUint64(&c.newHits),
+		OldHits:     atomic.LoadUint64(&c.oldHits),
+		Misses:      atomic.LoadUint64(&c.misses),
+		Backfills:   atomic.LoadUint64(&c.backfills),
+		WriteErrors: atomic.LoadUint64(&c.writeErrors),
+	}
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (interface{}, error) {
+	val, err := c.new.Get(ctx, key)
+	if err == nil || errors.Is(err, cache.ErrKeyNegative) {
+		atomic.AddUint64(&c.newHits, 1)
+		return val, err
+	}
+
+	oldVal, oldErr := c.old.Get(ctx, key)
+	if oldErr != nil {
+		atomic.AddUint64(&c.misses, 1)
+		// the new adapter is the reference, its error is returned
+		return val, err
+	}
+	atomic.AddUint64(&c.oldHits, 1)
+	if c.backfill > 0 && c.new.Put(ctx, key, oldVal, c.backfill) == nil {
+		atomic.AddUint64(&c.backfills, 1)
+	}
+	return oldVal, nil
+}
+
+func (c *Cache) GetMulti(ctx context.Context, keys []string) ([]interface{}, error) {
+	rc := make([]interface{}, len(keys))
+	keysErr := make([]string, 0)
+
+	for i, ki := range keys {
+		val, err := c.Get(ctx, ki)
+		if err != nil {
+			keysErr = append(keysErr, fmt.Sprintf("key [%s] error: %s", ki, err.Error()))
+			continue
+		}
+		rc[i] = val
+	}
+
+	if len(keysErr) == 0 {
+		return rc, nil
+	}
+	return rc, merror.Error(strings.Join(keysErr, "; "))
+}
+
+// Put writes to both adapters. When the write to the old adapter fails, the key is deleted from it,
+// and the error is returned if it still holds the key: Get would fall back to its stale value.
+func (c *Cache) Put(ctx context.Context, key string, val interface{}, timeout time.Duration) error {
+	newErr := c.new.Put(ctx, key, val, timeout)
+	if err := c.old.Put(ctx, key, val, timeout); err != nil {
+		atomic.AddUint64(&c.writeErrors, 1)
+		if c.deleteOld(ctx, key) != nil {
+			return merror.Wrapf(err, "could not write the key to the old cache, key: %s", key)
+		}
+	}
+	return newErr
+}
+
+// Delete deletes from both adapters. The error of the old adapter is returned if it still holds the key,
+// since Get would fall back to it and bring the deleted value back.
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	newErr := c.new.Delete(ctx, key)
+	if err := c.deleteOld(ctx, key); err != nil {
+		atomic.AddUint64(&c.writeErrors, 1)
+		return merror.Wrapf(err, "could not delete the key from the old cache, key: %s", key)
+	}
+	return newErr
+}
+
+// deleteOld deletes the key from the old adapter, the error is only returned if the key is still there.
+func (c *Cache) deleteOld(ctx context.Context, key string) error {
+	err := c.old.Delete(ctx, key)
+	if err == nil {
+		return nil
+	}
+	// some adapters fail to delete a missing key
+	if _, getErr := c.old.Get(ctx, key); getErr != nil {
+		return nil
+	}
+	return err
+}
+
+// Start reads the config
+// {"old": {"impl": "memcache", "config": {...}}, "new": {"impl": "redis", "config": {...}}, "backfill": 3600},
+// the adapters are built by cache.NewCache.
+func (c *Cache) Start(config string) error {
+	var cf options
+	if err := json.Unmarshal([]byte(config), &cf); err != nil {
+		return merror.Wrapf(err, "invalid config, please check your input: %s", config)
+	}
+	if cf.Old.Impl == "" || cf.New.Impl == "" {
+		return merror.Errorf(`config must contains "old" and "new" impl fields: %s`, config)
+	}
+
+	from, err := newBackend(cf.Old)
+	if err != nil {
+		return merror.Wrapf(err, "could not start the old cache: %s", cf.Old.Impl)
+	}
+	to, err := newBackend(cf.New)
+	if err != nil {
+		return merror.Wrapf(err, "could not start the new cache: %s", cf.New.Impl)
+	}
+	c.old, c.new = from, to
+	c.backfill = time.Duration(cf.Backfill) * time.Second
+	return nil
+}
+
+// newBackend accepts the config of the adapter as a JSON object or as a string holding it.
+func newBackend(b backend) (cache.Cache, error) {
+	config := string(b.Config)
+	var s string
+	if json.Unmarshal(b.Config, &s) == nil {
+		config = s
+	}
+	if config == "" {
+		config = "{}"
+	}
+	return cache.NewCache(b.Impl, config)
+}
+
+func init() {
+	cache.RegisterNewCacheImpl("migrating", NewMigratingCache)
+}
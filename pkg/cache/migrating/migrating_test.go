@@ -0,0 +1,248 @@
+package migrating
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/cache"
+	"github.com/go-monsters/monster/pkg/cache/memory"
+)
+
+var errDown = errors.New("cache down")
+
+// failingCache fails its writes and deletes on demand, on top of a memory cache.
+type failingCache struct {
+	cache.Cache
+	failPut    bool
+	failDelete bool
+}
+
+func (c *failingCache) Put(ctx context.Context, key string, val interface{}, timeout time.Duration) error {
+	if c.failPut {
+		return errDown
+	}
+	return c.Cache.Put(ctx, key, val, timeout)
+}
+
+func (c *failingCache) Delete(ctx context.Context, key string) error {
+	if c.failDelete {
+		return errDown
+	}
+	return c.Cache.Delete(ctx, key)
+}
+
+func newMemoryCache(t *testing.T) *failingCache {
+	t.Helper()
+	c := memory.NewMemoryCache()
+	if err := c.Start(`{"interval": 0}`); err != nil {
+		t.Fatal(err)
+	}
+	return &failingCache{Cache: c}
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name      string
+		old       map[string]interface{}
+		new       map[string]interface{}
+		backfill  time.Duration
+		want      interface{}
+		wantErr   error
+		wantStats Stats
+		wantNew   bool // the key is in the new adapter after the read
+	}{
+		{
+			name:      "new hit",
+			new:       map[string]interface{}{"k": "new"},
+			old:       map[string]interface{}{"k": "old"},
+			want:      "new",
+			wantStats: Stats{NewHits: 1},
+			wantNew:   true,
+		},
+		{
+			name:      "old hit",
+			old:       map[string]interface{}{"k": "old"},
+			want:      "old",
+			wantStats: Stats{OldHits: 1},
+		},
+		{
+			name:      "old hit backfilled",
+			old:       map[string]interface{}{"k": "old"},
+			backfill:  time.Minute,
+			want:      "old",
+			wantStats: Stats{OldHits: 1, Backfills: 1},
+			wantNew:   true,
+		},
+		{
+			name:      "miss",
+			wantErr:   cache.ErrKeyNotExist,
+			wantStats: Stats{Misses: 1},
+		},
+		{
+			name:      "negative entry in the new adapter",
+			new:       map[string]interface{}{"k": nil},
+			old:       map[string]interface{}{"k": "old"},
+			wantErr:   cache.ErrKeyNegative,
+			wantStats: Stats{NewHits: 1},
+			wantNew:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			from, to := newMemoryCache(t), newMemoryCache(t)
+			for k, v := range tt.old {
+				_ = from.Put(ctx, k, v, time.Minute)
+			}
+			for k, v := range tt.new {
+				if v == nil {
+					_ = cache.PutNegative(ctx, to, k, "not found", time.Minute)
+					continue
+				}
+				_ = to.Put(ctx, k, v, time.Minute)
+			}
+			c := New(from, to, tt.backfill)
+
+			got, err := c.Get(ctx, "k")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got != tt.want {
+				t.Errorf("Get = %v, want %v", got, tt.want)
+			}
+			if stats := c.Stats(); stats != tt.wantStats {
+				t.Errorf("Stats = %+v, want %+v", stats, tt.wantStats)
+			}
+			if _, err := to.Get(ctx, "k"); (err == nil || errors.Is(err, cache.ErrKeyNegative)) != tt.wantNew {
+				t.Errorf("key in the new adapter = %v, want %v", err == nil, tt.wantNew)
+			}
+		})
+	}
+}
+
+func TestPut(t *testing.T) {
+	tests := []struct {
+		name       string
+		failPut    bool
+		failDelete bool
+		wantErr    bool
+		wantOld    bool // the old adapter still holds a value after the write
+		wantStats  Stats
+	}{
+		{name: "both written", wantOld: true},
+		{name: "old write failed", failPut: true, wantStats: Stats{WriteErrors: 1}},
+		{name: "old write and delete failed", failPut: true, failDelete: true, wantErr: true, wantOld: true, wantStats: Stats{WriteErrors: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			from, to := newMemoryCache(t), newMemoryCache(t)
+			_ = from.Put(ctx, "k", "stale", time.Minute)
+			from.failPut, from.failDelete = tt.failPut, tt.failDelete
+			c := New(from, to, 0)
+
+			err := c.Put(ctx, "k", "fresh", time.Minute)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Put error = %v, want error %v", err, tt.wantErr)
+			}
+			if got, err := to.Get(ctx, "k"); err != nil || got != "fresh" {
+				t.Errorf("new adapter Get = %v, %v, want fresh", got, err)
+			}
+			if _, err := from.Get(ctx, "k"); (err == nil) != tt.wantOld {
+				t.Errorf("old adapter holds the key = %v, want %v", err == nil, tt.wantOld)
+			}
+			if stats := c.Stats(); stats != tt.wantStats {
+				t.Errorf("Stats = %+v, want %+v", stats, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name       string
+		failDelete bool
+		inOld      bool
+		wantErr    bool
+		wantStats  Stats
+	}{
+		{name: "deleted from both", inOld: true},
+		{name: "old delete failed", failDelete: true, inOld: true, wantErr: true, wantStats: Stats{WriteErrors: 1}},
+		{name: "old delete failed on a missing key", failDelete: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			from, to := newMemoryCache(t), newMemoryCache(t)
+			if tt.inOld {
+				_ = from.Put(ctx, "k", "value", time.Minute)
+			}
+			_ = to.Put(ctx, "k", "value", time.Minute)
+			from.failDelete = tt.failDelete
+			c := New(from, to, 0)
+
+			err := c.Delete(ctx, "k")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Delete error = %v, want error %v", err, tt.wantErr)
+			}
+			if _, err := to.Get(ctx, "k"); err == nil {
+				t.Error("the key is still in the new adapter")
+			}
+			if stats := c.Stats(); stats != tt.wantStats {
+				t.Errorf("Stats = %+v, want %+v", stats, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  interface{}
+		wantErr bool
+	}{
+		{
+			name: "object configs",
+			config: map[string]interface{}{
+				"old":      map[string]interface{}{"impl": "memory", "config": map[string]int{"interval": 0}},
+				"new":      map[string]interface{}{"impl": "memory", "config": map[string]int{"interval": 0}},
+				"backfill": 60,
+			},
+		},
+		{
+			name: "string configs",
+			config: map[string]interface{}{
+				"old": map[string]interface{}{"impl": "memory", "config": `{"interval": 0}`},
+				"new": map[string]interface{}{"impl": "memory"},
+			},
+		},
+		{
+			name:    "missing new",
+			config:  map[string]interface{}{"old": map[string]interface{}{"impl": "memory"}},
+			wantErr: true,
+		},
+		{
+			name: "unknown impl",
+			config: map[string]interface{}{
+				"old": map[string]interface{}{"impl": "memory"},
+				"new": map[string]interface{}{"impl": "unknown"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, _ := json.Marshal(tt.config)
+			c, err := cache.NewCache("migrating", string(config))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewCache error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (c.(*Cache).Old() == nil || c.(*Cache).New() == nil) {
+				t.Error("the adapters were not started")
+			}
+		})
+	}
+}
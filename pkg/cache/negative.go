@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+)
+
+// ErrKeyNegative is matched by errors.Is for the error returned by Get when the key holds a negative entry.
+var ErrKeyNegative = merror.Error("the key is cached as missing")
+
+// negativePrefix starts every negative entry, it is a plain string so that it round-trips
+// through the codec of every adapter (gob, redis and memcache strings, raw memory values).
+const negativePrefix = "\x00monster:negative\x00"
+
+// NegativeError is returned by Get for a negative entry, Reason is the one given to PutNegative.
+type NegativeError struct {
+	Key    string
+	Reason string
+}
+
+func (e *NegativeError) Error() string {
+	if e.Reason == "" {
+		return ErrKeyNegative.Error() + ", key: " + e.Key
+	}
+	return ErrKeyNegative.Error() + ", key: " + e.Key + ", reason: " + e.Reason
+}
+
+func (e *NegativeError) Is(target error) bool {
+	return target == ErrKeyNegative
+}
+
+// PutNegative caches the fact that key has no value, for example a missing record or an error of
+// the loader, the reason is kept for NegativeError. The timeout is usually shorter than for values.
+func PutNegative(ctx context.Context, c Cache, key, reason string, timeout time.Duration) error {
+	return c.Put(ctx, key, negativePrefix+reason, timeout)
+}
+
+// Negative returns a *NegativeError if val is a negative entry read from key, nil otherwise.
+// The adapters call it on every value they read.
+func Negative(key string, val interface{}) error {
+	var s string
+	switch v := val.(type) {
+	case string:
+		s = v
+	case []byte:
+		if len(v) < len(negativePrefix) || string(v[:len(negativePrefix)]) != negativePrefix {
+			return nil
+		}
+		s = string(v)
+	default:
+		return nil
+	}
+	if !strings.HasPrefix(s, negativePrefix) {
+		return nil
+	}
+	return &NegativeError{Key: key, Reason: s[len(negativePrefix):]}
+}
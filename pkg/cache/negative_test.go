@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+)
+
+func TestNegative(t *testing.T) {
+	tests := []struct {
+		name       string
+		val        interface{}
+		want       bool
+		wantReason string
+	}{
+		{name: "string entry", val: negativePrefix + "not found", want: true, wantReason: "not found"},
+		{name: "bytes entry", val: []byte(negativePrefix + "not found"), want: true, wantReason: "not found"},
+		{name: "no reason", val: negativePrefix, want: true},
+		{name: "plain string", val: "value"},
+		{name: "plain bytes", val: []byte("value")},
+		{name: "short bytes", val: []byte("\x00")},
+		{name: "prefix inside the value", val: "value" + negativePrefix},
+		{name: "other type", val: 42},
+		{name: "nil", val: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Negative("k", tt.val)
+			if (err != nil) != tt.want {
+				t.Fatalf("Negative(%q) = %v, want a negative entry %v", tt.val, err, tt.want)
+			}
+			if err == nil {
+				return
+			}
+			var negative *NegativeError
+			if !errors.As(err, &negative) || negative.Key != "k" || negative.Reason != tt.wantReason {
+				t.Errorf("Negative(%q) = %#v, want the key k and the reason %q", tt.val, err, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestNegativeError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "reason", err: &NegativeError{Key: "k", Reason: "not found"}, want: ErrKeyNegative.Error() + ", key: k, reason: not found"},
+		{name: "no reason", err: &NegativeError{Key: "k"}, want: ErrKeyNegative.Error() + ", key: k"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+			if !errors.Is(tt.err, ErrKeyNegative) || !errors.Is(merror.Wrap(tt.err, "get"), ErrKeyNegative) {
+				t.Errorf("errors.Is(%v, ErrKeyNegative) = false", tt.err)
+			}
+			if errors.Is(tt.err, ErrKeyNotExist) {
+				t.Errorf("errors.Is(%v, ErrKeyNotExist) = true", tt.err)
+			}
+		})
+	}
+}
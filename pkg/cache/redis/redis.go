@@ -3,6 +3,8 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +18,8 @@ import (
 
 var defaultKey = "monsterCacheRedis"
 
+const scanCount = 100 // hint of the number of keys returned by every SCAN call
+
 type Cache struct {
 	conn     apmgoredis.Client
 	connInfo string
@@ -36,9 +40,15 @@ func (c *Cache) GetClient() interface{} {
 func (c *Cache) Get(ctx context.Context, key string) (interface{}, error) {
 	client := c.conn.WithContext(ctx)
 	res := client.Get(key)
+	if errors.Is(res.Err(), redis.Nil) {
+		return nil, merror.Wrapf(cache.ErrKeyNotExist, "key: %s", key)
+	}
 	if res.Err() != nil {
 		return nil, merror.Wrapf(res.Err(), "error with get")
 	}
+	if err := cache.Negative(key, res.Val()); err != nil {
+		return nil, err
+	}
 	return res.Val(), nil
 }
 
@@ -57,7 +67,18 @@ func (c *Cache) GetMulti(ctx context.Context, keys []string) ([]interface{}, err
 	if err != nil {
 		return nil, res.Err()
 	}
-	return values, nil
+
+	keysErr := make([]string, 0)
+	for i, val := range values {
+		if err := cache.Negative(keys[i], val); err != nil {
+			values[i] = nil
+			keysErr = append(keysErr, fmt.Sprintf("key [%s] error: %s", keys[i], err.Error()))
+		}
+	}
+	if len(keysErr) == 0 {
+		return values, nil
+	}
+	return values, merror.Error(strings.Join(keysErr, "; "))
 }
 
 func (c *Cache) Put(ctx context.Context, key string, val interface{}, timeout time.Duration) error {
@@ -78,6 +99,29 @@ func (c *Cache) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+func (c *Cache) Scan(ctx context.Context, pattern string, fn cache.ScanFunc) error {
+	client := c.conn.WithContext(ctx)
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(cursor, pattern, scanCount).Result()
+		if err != nil {
+			return merror.Wrapf(err, "error with scan, pattern: %s", pattern)
+		}
+		for _, key := range keys {
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cursor = next
+	}
+}
+
 func (c *Cache) Start(config string) error {
 	var cf map[string]string
 	err := json.Unmarshal([]byte(config), &cf)
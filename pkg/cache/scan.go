@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+)
+
+var (
+	ErrScanNotSupported = merror.Error("the cache adapter doesn't support scan")
+	// ErrScanStop can be returned by a ScanFunc to stop the iteration without error.
+	ErrScanStop = merror.Error("stop scan")
+)
+
+// ScanFunc is called once per key matched by Scan. Returning an error stops the iteration.
+type ScanFunc func(key string) error
+
+// Scanner is implemented by adapters which are able to iterate their keys.
+// The pattern is a glob, see MatchPattern.
+type Scanner interface {
+	Scan(ctx context.Context, pattern string, fn ScanFunc) error
+}
+
+// Scan iterates the keys of c matching pattern,
+// it returns ErrScanNotSupported if the adapter doesn't implement Scanner.
+func Scan(ctx context.Context, c Cache, pattern string, fn ScanFunc) error {
+	s, ok := c.(Scanner)
+	if !ok {
+		return ErrScanNotSupported
+	}
+	err := s.Scan(ctx, pattern, fn)
+	if err == ErrScanStop {
+		return nil
+	}
+	return err
+}
+
+// MatchPattern reports whether key matches the glob pattern, using the same syntax as redis:
+// "*" matches any sequence of characters, "?" matches a single character,
+// "[abc]", "[^a]" and "[a-z]" match a character class and "\\" escapes the next character.
+//
+// On a mismatch only the last star is retried one character further, so it takes at most
+// O(len(pattern)*len(key)) steps, never the exponential time of a recursive matcher.
+func MatchPattern(pattern, key string) bool {
+	p, k := 0, 0
+	star, starKey := -1, 0
+	for k < len(key) || p < len(pattern) {
+		if p < len(pattern) {
+			if pattern[p] == '*' {
+				for p < len(pattern) && pattern[p] == '*' {
+					p++
+				}
+				star, starKey = p, k
+				continue
+			}
+			if k < len(key) {
+				c, size := utf8.DecodeRuneInString(key[k:])
+				if next, ok := matchOne(pattern, p, c); ok {
+					p, k = next, k+size
+					continue
+				}
+			}
+		}
+		if star < 0 || starKey >= len(key) {
+			return false
+		}
+		// let the last star match one more character
+		_, size := utf8.DecodeRuneInString(key[starKey:])
+		starKey += size
+		p, k = star, starKey
+	}
+	return true
+}
+
+// matchOne matches c against the element of pattern at p, which is not a star,
+// it returns the position of the next element.
+func matchOne(pattern string, p int, c rune) (int, bool) {
+	switch pattern[p] {
+	case '?':
+		return p + 1, true
+	case '[':
+		return matchClass(pattern, p+1, c)
+	case '\\':
+		if p+1 < len(pattern) {
+			p++
+		}
+	}
+	r, size := utf8.DecodeRuneInString(pattern[p:])
+	return p + size, r == c
+}
+
+// matchClass matches c against the class at p in pattern (after the opening bracket),
+// it returns the position after the closing bracket.
+func matchClass(pattern string, p int, c rune) (int, bool) {
+	negate := false
+	if p < len(pattern) && pattern[p] == '^' {
+		negate = true
+		p++
+	}
+	matched := false
+	for p < len(pattern) && pattern[p] != ']' {
+		if pattern[p] == '\\' && p+1 < len(pattern) {
+			p++
+		}
+		lo, size := utf8.DecodeRuneInString(pattern[p:])
+		p += size
+		hi := lo
+		if p+1 < len(pattern) && pattern[p] == '-' && pattern[p+1] != ']' {
+			var size int
+			hi, size = utf8.DecodeRuneInString(pattern[p+1:])
+			p += 1 + size
+		}
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+	if p < len(pattern) {
+		// skip the closing bracket
+		p++
+	}
+	return p, matched != negate
+}
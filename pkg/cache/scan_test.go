@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{pattern: "", key: "", want: true},
+		{pattern: "", key: "a", want: false},
+		{pattern: "*", key: "", want: true},
+		{pattern: "*", key: "anything", want: true},
+		{pattern: "user:*", key: "user:42", want: true},
+		{pattern: "user:*", key: "user:", want: true},
+		{pattern: "user:*", key: "users:42", want: false},
+		{pattern: "*:42", key: "user:42", want: true},
+		{pattern: "*:42", key: "user:420", want: false},
+		{pattern: "a*b*c", key: "aXbYc", want: true},
+		{pattern: "a*b*c", key: "abcbc", want: true},
+		{pattern: "a*b*c", key: "aXbYcZ", want: false},
+		{pattern: "a**b", key: "ab", want: true},
+		{pattern: "h?llo", key: "hello", want: true},
+		{pattern: "h?llo", key: "hllo", want: false},
+		{pattern: "h?llo", key: "héllo", want: true},
+		{pattern: "h[ae]llo", key: "hallo", want: true},
+		{pattern: "h[ae]llo", key: "hillo", want: false},
+		{pattern: "h[^e]llo", key: "hallo", want: true},
+		{pattern: "h[^e]llo", key: "hello", want: false},
+		{pattern: "h[a-c]llo", key: "hbllo", want: true},
+		{pattern: "h[c-a]llo", key: "hbllo", want: true},
+		{pattern: "h[a-c]llo", key: "hdllo", want: false},
+		{pattern: "h[\\]]llo", key: "h]llo", want: true},
+		{pattern: "h[a-]llo", key: "h-llo", want: true},
+		{pattern: "h[ab", key: "ha", want: true},
+		{pattern: "\\*", key: "*", want: true},
+		{pattern: "\\*", key: "a", want: false},
+		{pattern: "a\\?", key: "a?", want: true},
+		{pattern: "a\\?", key: "ab", want: false},
+		{pattern: "a\\", key: "a\\", want: true},
+		{pattern: strings.Repeat("a*", 30) + "b", key: strings.Repeat("a", 200), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.key, func(t *testing.T) {
+			if got := MatchPattern(tt.pattern, tt.key); got != tt.want {
+				t.Errorf("MatchPattern(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+// keysCache is a Cache scanning a fixed list of keys.
+type keysCache struct {
+	Cache
+	keys []string
+}
+
+func (c *keysCache) Scan(ctx context.Context, pattern string, fn ScanFunc) error {
+	for _, key := range c.keys {
+		if !MatchPattern(pattern, key) {
+			continue
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noScanCache is a Cache which doesn't implement Scanner.
+type noScanCache struct {
+	Cache
+}
+
+func TestScan(t *testing.T) {
+	errFailed := errors.New("failed")
+	keys := &keysCache{keys: []string{"user:1", "user:2", "order:1", "user:3"}}
+	tests := []struct {
+		name    string
+		cache   Cache
+		pattern string
+		stopAt  int   // the number of keys after which fn returns fnErr, 0 never
+		fnErr   error // returned by fn at stopAt
+		want    []string
+		wantErr error
+	}{
+		{name: "every key", cache: keys, pattern: "*", want: []string{"user:1", "user:2", "order:1", "user:3"}},
+		{name: "matching keys", cache: keys, pattern: "user:*", want: []string{"user:1", "user:2", "user:3"}},
+		{name: "no match", cache: keys, pattern: "item:*"},
+		{name: "stop", cache: keys, pattern: "user:*", stopAt: 2, fnErr: ErrScanStop, want: []string{"user:1", "user:2"}},
+		{name: "error", cache: keys, pattern: "*", stopAt: 1, fnErr: errFailed, want: []string{"user:1"}, wantErr: errFailed},
+		{name: "not supported", cache: noScanCache{}, pattern: "*", wantErr: ErrScanNotSupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := Scan(context.Background(), tt.cache, tt.pattern, func(key string) error {
+				got = append(got, key)
+				if len(got) == tt.stopAt {
+					return tt.fnErr
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Scan error = %v, want %v", err, tt.wantErr)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Scan keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkMatchPatternStars(b *testing.B) {
+	pattern := strings.Repeat("a*", 30) + "b"
+	key := strings.Repeat("a", 1000)
+	for i := 0; i < b.N; i++ {
+		MatchPattern(pattern, key)
+	}
+}
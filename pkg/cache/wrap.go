@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	OpGet      = "Get"
+	OpGetMulti = "GetMulti"
+	OpPut      = "Put"
+	OpDelete   = "Delete"
+)
+
+// Call describes a single operation on a wrapped cache.
+type Call struct {
+	Op      string
+	Keys    []string      // a single key except for GetMulti
+	Value   interface{}   // the value for Put
+	Timeout time.Duration // the timeout for Put
+}
+
+// Key returns the first key of the call.
+func (c *Call) Key() string {
+	if len(c.Keys) == 0 {
+		return ""
+	}
+	return c.Keys[0]
+}
+
+// Invoker runs the call, the result is interface{} for Get, []interface{} for GetMulti and nil otherwise.
+type Invoker func(ctx context.Context, call *Call) (interface{}, error)
+
+// Interceptor sees every call made to a wrapped cache, it must call next to continue the chain.
+type Interceptor func(ctx context.Context, call *Call, next Invoker) (interface{}, error)
+
+type wrapped struct {
+	Cache
+	invoke Invoker
+}
+
+// Wrap returns a Cache which runs every Get, GetMulti, Put and Delete through the interceptors,
+// the first interceptor is the outermost one.
+func Wrap(c Cache, interceptors ...Interceptor) Cache {
+	w := &wrapped{Cache: c}
+	w.invoke = w.call
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], w.invoke
+		w.invoke = func(ctx context.Context, call *Call) (interface{}, error) {
+			return interceptor(ctx, call, next)
+		}
+	}
+	return w
+}
+
+// Unwrap returns the cache given to Wrap.
+func (w *wrapped) Unwrap() Cache {
+	return w.Cache
+}
+
+func (w *wrapped) Get(ctx context.Context, key string) (interface{}, error) {
+	return w.invoke(ctx, &Call{Op: OpGet, Keys: []string{key}})
+}
+
+func (w *wrapped) GetMulti(ctx context.Context, keys []string) ([]interface{}, error) {
+	res, err := w.invoke(ctx, &Call{Op: OpGetMulti, Keys: keys})
+	values, _ := res.([]interface{})
+	return values, err
+}
+
+func (w *wrapped) Put(ctx context.Context, key string, val interface{}, timeout time.Duration) error {
+	_, err := w.invoke(ctx, &Call{Op: OpPut, Keys: []string{key}, Value: val, Timeout: timeout})
+	return err
+}
+
+func (w *wrapped) Delete(ctx context.Context, key string) error {
+	_, err := w.invoke(ctx, &Call{Op: OpDelete, Keys: []string{key}})
+	return err
+}
+
+func (w *wrapped) Scan(ctx context.Context, pattern string, fn ScanFunc) error {
+	return Scan(ctx, w.Cache, pattern, fn)
+}
+
+func (w *wrapped) call(ctx context.Context, call *Call) (interface{}, error) {
+	switch call.Op {
+	case OpGet:
+		return w.Cache.Get(ctx, call.Key())
+	case OpGetMulti:
+		return w.Cache.GetMulti(ctx, call.Keys)
+	case OpPut:
+		return nil, w.Cache.Put(ctx, call.Key(), call.Value, call.Timeout)
+	case OpDelete:
+		return nil, w.Cache.Delete(ctx, call.Key())
+	}
+	return nil, nil
+}
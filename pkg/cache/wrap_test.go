@@ -0,0 +1,154 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/cache"
+	"github.com/go-monsters/monster/pkg/cache/memory"
+)
+
+func newMemoryCache(t *testing.T) cache.Cache {
+	t.Helper()
+	c := memory.NewMemoryCache()
+	if err := c.Start(`{"interval": 0}`); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// recording returns an interceptor appending its name and the operation of every call to trace.
+func recording(name string, trace *[]string) cache.Interceptor {
+	return func(ctx context.Context, call *cache.Call, next cache.Invoker) (interface{}, error) {
+		*trace = append(*trace, name+">"+call.Op)
+		res, err := next(ctx, call)
+		*trace = append(*trace, name+"<"+call.Op)
+		return res, err
+	}
+}
+
+func TestWrap(t *testing.T) {
+	errDenied := errors.New("denied")
+	tests := []struct {
+		name      string
+		call      func(ctx context.Context, c cache.Cache) (interface{}, error)
+		deny      bool // the inner interceptor answers without calling next
+		wantTrace []string
+		want      interface{}
+		wantErr   error
+	}{
+		{
+			name: "get",
+			call: func(ctx context.Context, c cache.Cache) (interface{}, error) {
+				return c.Get(ctx, "a")
+			},
+			wantTrace: []string{"outer>Get", "inner>Get", "inner<Get", "outer<Get"},
+			want:      "A",
+		},
+		{
+			name: "get multi",
+			call: func(ctx context.Context, c cache.Cache) (interface{}, error) {
+				return c.GetMulti(ctx, []string{"a", "b"})
+			},
+			wantTrace: []string{"outer>GetMulti", "inner>GetMulti", "inner<GetMulti", "outer<GetMulti"},
+			want:      []interface{}{"A", "B"},
+		},
+		{
+			name: "put",
+			call: func(ctx context.Context, c cache.Cache) (interface{}, error) {
+				if err := c.Put(ctx, "c", "C", time.Minute); err != nil {
+					return nil, err
+				}
+				return c.Get(ctx, "c")
+			},
+			wantTrace: []string{
+				"outer>Put", "inner>Put", "inner<Put", "outer<Put",
+				"outer>Get", "inner>Get", "inner<Get", "outer<Get",
+			},
+			want: "C",
+		},
+		{
+			name: "delete",
+			call: func(ctx context.Context, c cache.Cache) (interface{}, error) {
+				if err := c.Delete(ctx, "a"); err != nil {
+					return nil, err
+				}
+				return c.Get(ctx, "a")
+			},
+			wantTrace: []string{
+				"outer>Delete", "inner>Delete", "inner<Delete", "outer<Delete",
+				"outer>Get", "inner>Get", "inner<Get", "outer<Get",
+			},
+			wantErr: cache.ErrKeyNotExist,
+		},
+		{
+			name: "short circuit",
+			call: func(ctx context.Context, c cache.Cache) (interface{}, error) {
+				return c.Get(ctx, "a")
+			},
+			deny:      true,
+			wantTrace: []string{"outer>Get", "outer<Get"},
+			wantErr:   errDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := newMemoryCache(t)
+			_ = c.Put(ctx, "a", "A", time.Minute)
+			_ = c.Put(ctx, "b", "B", time.Minute)
+
+			var trace []string
+			inner := recording("inner", &trace)
+			if tt.deny {
+				inner = func(ctx context.Context, call *cache.Call, next cache.Invoker) (interface{}, error) {
+					return nil, errDenied
+				}
+			}
+			got, err := tt.call(ctx, cache.Wrap(c, recording("outer", &trace), inner))
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result = %v, want %v", got, tt.want)
+			}
+			if strings.Join(trace, " ") != strings.Join(tt.wantTrace, " ") {
+				t.Errorf("trace = %v, want %v", trace, tt.wantTrace)
+			}
+		})
+	}
+}
+
+func TestWrapCall(t *testing.T) {
+	var got cache.Call
+	c := cache.Wrap(newMemoryCache(t), func(ctx context.Context, call *cache.Call, next cache.Invoker) (interface{}, error) {
+		got = *call
+		return next(ctx, call)
+	})
+	if err := c.Put(context.Background(), "a", "A", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	want := cache.Call{Op: cache.OpPut, Keys: []string{"a"}, Value: "A", Timeout: time.Minute}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("call = %+v, want %+v", got, want)
+	}
+}
+
+func TestWrapScan(t *testing.T) {
+	ctx := context.Background()
+	c := newMemoryCache(t)
+	_ = c.Put(ctx, "a", "A", time.Minute)
+	var keys []string
+	err := cache.Scan(ctx, cache.Wrap(c), "*", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil || len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("Scan = %v, %v, want [a]", keys, err)
+	}
+}
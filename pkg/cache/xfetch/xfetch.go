@@ -0,0 +1,161 @@
+// Package xfetch implements read-through caching with probabilistic early recomputation (XFetch),
+// readers recompute a value before it expires with a probability growing as the expiry gets closer,
+// so a fleet refreshes a hot key once instead of all at once.
+package xfetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/cache"
+)
+
+// DefaultBeta recomputes early as suggested by the paper, a greater beta recomputes earlier.
+const DefaultBeta = 1.0
+
+var (
+	rndMu sync.Mutex
+	rnd   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// Loader computes the value of a key on a cache miss or an early recomputation.
+type Loader[T any] func(ctx context.Context) (T, error)
+
+// entry is stored in the cache as JSON, so it round-trips through every adapter.
+type entry[T any] struct {
+	Value  T             `json:"v"`
+	Delta  time.Duration `json:"d"` // the time it took to compute the value
+	Expiry time.Time     `json:"e"` // zero means forever
+}
+
+type call[T any] struct {
+	wg  sync.WaitGroup
+	val T
+	err error
+}
+
+// Fetcher reads values of type T through a cache.Cache.
+// The calls recomputing the same key in this process are coalesced.
+type Fetcher[T any] struct {
+	cache cache.Cache
+	Beta  float64
+	// NegativeTTL caches the errors of the loader as negative entries when it is greater than 0,
+	// so a missing record is not loaded again by every request until it expires.
+	NegativeTTL time.Duration
+
+	mu    sync.Mutex
+	calls map[string]*call[T]
+}
+
+func New[T any](c cache.Cache) *Fetcher[T] {
+	return &Fetcher[T]{
+		cache: c,
+		Beta:  DefaultBeta,
+		calls: make(map[string]*call[T]),
+	}
+}
+
+// Fetch returns the cached value of key, or calls load and caches its result for ttl
+// when the key is missing, expired or elected for an early recomputation.
+// A negative entry is returned as a *cache.NegativeError.
+func (f *Fetcher[T]) Fetch(ctx context.Context, key string, ttl time.Duration, load Loader[T]) (T, error) {
+	e, ok, err := f.get(ctx, key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if ok && !f.recomputeEarly(e) {
+		return e.Value, nil
+	}
+	return f.load(ctx, key, ttl, load)
+}
+
+// get returns an error only for a negative entry, any other failure is a miss.
+func (f *Fetcher[T]) get(ctx context.Context, key string) (*entry[T], bool, error) {
+	raw, err := f.cache.Get(ctx, key)
+	if errors.Is(err, cache.ErrKeyNegative) {
+		return nil, false, err
+	}
+	if err != nil {
+		return nil, false, nil
+	}
+	var data []byte
+	switch v := raw.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil, false, nil
+	}
+	var e entry[T]
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, false, nil
+	}
+	return &e, true, nil
+}
+
+// recomputeEarly is true when now - delta * beta * ln(rand()) >= expiry.
+func (f *Fetcher[T]) recomputeEarly(e *entry[T]) bool {
+	if e.Expiry.IsZero() {
+		return false
+	}
+	rndMu.Lock()
+	r := rnd.Float64()
+	rndMu.Unlock()
+	if r == 0 {
+		r = math.SmallestNonzeroFloat64
+	}
+	gap := time.Duration(-float64(e.Delta) * f.Beta * math.Log(r))
+	return !time.Now().Add(gap).Before(e.Expiry)
+}
+
+func (f *Fetcher[T]) load(ctx context.Context, key string, ttl time.Duration, load Loader[T]) (T, error) {
+	f.mu.Lock()
+	if c, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := &call[T]{}
+	c.wg.Add(1)
+	f.calls[key] = c
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	start := time.Now()
+	c.val, c.err = load(ctx)
+	if c.err != nil {
+		if f.NegativeTTL > 0 {
+			_ = cache.PutNegative(ctx, f.cache, key, c.err.Error(), f.NegativeTTL)
+		}
+		return c.val, c.err
+	}
+
+	now := time.Now()
+	e := entry[T]{Value: c.val, Delta: now.Sub(start)}
+	// 0 means forever
+	if ttl > 0 {
+		e.Expiry = now.Add(ttl)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		c.err = merror.Wrapf(err, "could not encode the value of the key: %s", key)
+		return c.val, c.err
+	}
+	// the value is returned even if it could not be cached
+	_ = f.cache.Put(ctx, key, string(data), ttl)
+	return c.val, nil
+}
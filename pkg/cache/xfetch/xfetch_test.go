@@ -0,0 +1,186 @@
+package xfetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/cache"
+	"github.com/go-monsters/monster/pkg/cache/memory"
+)
+
+func newMemoryCache(t *testing.T) cache.Cache {
+	t.Helper()
+	c := memory.NewMemoryCache()
+	if err := c.Start(`{"interval": 0}`); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// putEntry caches value as if it took delta to compute and expires in expiresIn, 0 means forever.
+func putEntry(t *testing.T, c cache.Cache, key string, value int, delta, expiresIn time.Duration) {
+	t.Helper()
+	e := entry[int]{Value: value, Delta: delta}
+	if expiresIn != 0 {
+		e.Expiry = time.Now().Add(expiresIn)
+	}
+	data, _ := json.Marshal(e)
+	if err := c.Put(context.Background(), key, string(data), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	errLoad := errors.New("load failed")
+	tests := []struct {
+		name      string
+		prepare   func(t *testing.T, c cache.Cache)
+		beta      float64
+		loadErr   error
+		want      int
+		wantLoads int32
+		wantErr   error
+	}{
+		{name: "miss", want: 2, wantLoads: 1},
+		{
+			name:    "fresh hit",
+			prepare: func(t *testing.T, c cache.Cache) { putEntry(t, c, "k", 1, time.Millisecond, time.Hour) },
+			beta:    DefaultBeta,
+			want:    1,
+		},
+		{
+			name:    "hit kept forever",
+			prepare: func(t *testing.T, c cache.Cache) { putEntry(t, c, "k", 1, time.Hour, 0) },
+			beta:    1e6,
+			want:    1,
+		},
+		{
+			name:      "hit close to its expiry",
+			prepare:   func(t *testing.T, c cache.Cache) { putEntry(t, c, "k", 1, time.Hour, time.Second) },
+			beta:      1e6,
+			want:      2,
+			wantLoads: 1,
+		},
+		{
+			name:    "no early recomputation",
+			prepare: func(t *testing.T, c cache.Cache) { putEntry(t, c, "k", 1, time.Hour, time.Second) },
+			beta:    0,
+			want:    1,
+		},
+		{
+			name:      "entry of another format",
+			prepare:   func(t *testing.T, c cache.Cache) { _ = c.Put(context.Background(), "k", 1, time.Hour) },
+			want:      2,
+			wantLoads: 1,
+		},
+		{
+			name: "negative entry",
+			prepare: func(t *testing.T, c cache.Cache) {
+				_ = cache.PutNegative(context.Background(), c, "k", "not found", time.Hour)
+			},
+			wantErr: cache.ErrKeyNegative,
+		},
+		{name: "load error", loadErr: errLoad, wantLoads: 1, wantErr: errLoad},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMemoryCache(t)
+			if tt.prepare != nil {
+				tt.prepare(t, c)
+			}
+			f := New[int](c)
+			f.Beta = tt.beta
+
+			var loads int32
+			got, err := f.Fetch(context.Background(), "k", time.Hour, func(ctx context.Context) (int, error) {
+				atomic.AddInt32(&loads, 1)
+				return 2, tt.loadErr
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Fetch error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got != tt.want {
+				t.Errorf("Fetch = %d, want %d", got, tt.want)
+			}
+			if loads != tt.wantLoads {
+				t.Errorf("%d loads, want %d", loads, tt.wantLoads)
+			}
+		})
+	}
+}
+
+func TestFetchCachesTheLoadedValue(t *testing.T) {
+	ctx := context.Background()
+	f := New[int](newMemoryCache(t))
+	var loads int32
+	load := func(ctx context.Context) (int, error) {
+		return int(atomic.AddInt32(&loads, 1)), nil
+	}
+	for i := 0; i < 3; i++ {
+		if got, err := f.Fetch(ctx, "k", time.Hour, load); err != nil || got != 1 {
+			t.Fatalf("Fetch = %d, %v, want 1", got, err)
+		}
+	}
+}
+
+func TestNegativeTTL(t *testing.T) {
+	ctx := context.Background()
+	f := New[int](newMemoryCache(t))
+	f.NegativeTTL = time.Minute
+	var loads int32
+	load := func(ctx context.Context) (int, error) {
+		atomic.AddInt32(&loads, 1)
+		return 0, errors.New("not found")
+	}
+
+	if _, err := f.Fetch(ctx, "k", time.Hour, load); err == nil {
+		t.Fatal("Fetch error = nil, want the error of the loader")
+	}
+	_, err := f.Fetch(ctx, "k", time.Hour, load)
+	var negative *cache.NegativeError
+	if !errors.As(err, &negative) || negative.Reason != "not found" {
+		t.Errorf("Fetch error = %v, want the negative entry of the failed load", err)
+	}
+	if loads != 1 {
+		t.Errorf("%d loads, want 1", loads)
+	}
+}
+
+func TestFetchCoalesces(t *testing.T) {
+	ctx := context.Background()
+	f := New[int](newMemoryCache(t))
+	var loads int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (int, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return 1, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := f.Fetch(ctx, "k", time.Hour, load); err != nil || got != 1 {
+				t.Errorf("Fetch = %d, %v, want 1", got, err)
+			}
+		}()
+	}
+	// let the readers reach the loader before it returns
+	for atomic.LoadInt32(&loads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("%d loads, want 1", loads)
+	}
+}
@@ -0,0 +1,33 @@
+package fiber
+
+import (
+	"net/url"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/orm/pagination"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PageRequest reads the pagination request from the query of c.
+func PageRequest(c *fiber.Ctx) (pagination.Request, error) {
+	query, err := url.ParseQuery(string(c.Request().URI().QueryString()))
+	if err != nil {
+		return pagination.Request{}, merror.Wrap(pagination.ErrInvalidRequest, err.Error())
+	}
+	return pagination.ParseQuery(query)
+}
+
+// WritePage answers c with the page as a pagination.Response, with its next and prev links
+// in the Link header too. The links are relative to the URL of c.
+func WritePage[T any](c *fiber.Ctx, page *pagination.Page[T]) error {
+	base, err := url.Parse(c.OriginalURL())
+	if err != nil {
+		return merror.Wrapf(err, "could not parse the url %s", c.OriginalURL())
+	}
+	res := page.Response(base)
+	if header := res.Links.Header(); header != "" {
+		c.Set(fiber.HeaderLink, header)
+	}
+	return c.JSON(res)
+}
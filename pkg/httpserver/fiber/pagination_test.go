@@ -0,0 +1,69 @@
+package fiber
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-monsters/monster/pkg/orm/pagination"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantLink   string
+		wantBody   string
+	}{
+		{target: "/users", wantStatus: 200, wantBody: `{"data":["a"],"links":{}}`},
+		{
+			target:     "/users?sort=name&limit=1",
+			wantStatus: 200,
+			wantLink:   `</users?cursor=next&limit=1&sort=name>; rel="next"`,
+			wantBody:   `{"data":["a"],"links":{"next":"/users?cursor=next&limit=1&sort=name"}}`,
+		},
+		{target: "/users?limit=x", wantStatus: 400},
+	}
+	app := fiber.New()
+	app.Get("/users", func(c *fiber.Ctx) error {
+		req, err := PageRequest(c)
+		if err != nil {
+			return c.SendStatus(400)
+		}
+		page := &pagination.Page[string]{Items: []string{"a"}}
+		if req.Limit == 1 {
+			page.Next = &pagination.Request{Cursor: "next", Limit: 1}
+		}
+		return WritePage(c, page)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			res, err := app.Test(httptest.NewRequest("GET", tt.target, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+			if link := res.Header.Get("Link"); link != tt.wantLink {
+				t.Errorf("Link = %s, want %s", link, tt.wantLink)
+			}
+			body, _ := io.ReadAll(res.Body)
+			var got, want pagination.Response[string]
+			_ = json.Unmarshal(body, &got)
+			_ = json.Unmarshal([]byte(tt.wantBody), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("body = %s, want %s", body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package nethttp
+
+import (
+	"net/http"
+
+	"github.com/go-monsters/monster/pkg/httpserver"
+	"github.com/go-monsters/monster/pkg/orm/pagination"
+)
+
+// PageRequest reads the pagination request from the query of r.
+func PageRequest(r *http.Request) (pagination.Request, error) {
+	return httpserver.PageRequest(r)
+}
+
+// WritePage answers r with the page as a pagination.Response, with its next and prev links
+// in the Link header too. The links are relative to the URL of r.
+func WritePage[T any](w http.ResponseWriter, r *http.Request, page *pagination.Page[T]) error {
+	return httpserver.WritePage(w, r, page)
+}
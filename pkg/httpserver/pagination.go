@@ -0,0 +1,25 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-monsters/monster/pkg/orm/pagination"
+)
+
+// PageRequest reads the pagination request from the query of r,
+// for the adapters built on net/http.
+func PageRequest(r *http.Request) (pagination.Request, error) {
+	return pagination.ParseQuery(r.URL.Query())
+}
+
+// WritePage answers r with the page as a pagination.Response, with its next and prev links
+// in the Link header too. The links are relative to the URL of r.
+func WritePage[T any](w http.ResponseWriter, r *http.Request, page *pagination.Page[T]) error {
+	res := page.Response(r.URL)
+	if header := res.Links.Header(); header != "" {
+		w.Header().Set("Link", header)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,85 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-monsters/monster/pkg/orm/pagination"
+)
+
+func TestPageRequest(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    pagination.Request
+		wantErr bool
+	}{
+		{target: "/users", want: pagination.Request{}},
+		{target: "/users?cursor=abc&limit=5", want: pagination.Request{Cursor: "abc", Limit: 5}},
+		{target: "/users?page=2", want: pagination.Request{Page: 2}},
+		{target: "/users?limit=0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			got, err := PageRequest(httptest.NewRequest("GET", tt.target, nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PageRequest error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("PageRequest = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWritePage(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     pagination.Page[string]
+		wantLink string
+		wantBody string
+	}{
+		{
+			name:     "single page",
+			page:     pagination.Page[string]{Items: []string{"a"}},
+			wantBody: `{"data":["a"],"links":{}}`,
+		},
+		{
+			name:     "next page",
+			page:     pagination.Page[string]{Items: []string{"a"}, Next: &pagination.Request{Cursor: "abc", Limit: 1}},
+			wantLink: `</users?cursor=abc&limit=1&sort=name>; rel="next"`,
+			wantBody: `{"data":["a"],"links":{"next":"/users?cursor=abc&limit=1&sort=name"}}`,
+		},
+		{
+			name:     "empty page",
+			page:     pagination.Page[string]{},
+			wantBody: `{"data":[],"links":{}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := WritePage(w, httptest.NewRequest("GET", "/users?sort=name&limit=1", nil), &tt.page); err != nil {
+				t.Fatal(err)
+			}
+			if link := w.Header().Get("Link"); link != tt.wantLink {
+				t.Errorf("Link = %s, want %s", link, tt.wantLink)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %s, want application/json", ct)
+			}
+			var got, want interface{}
+			_ = json.Unmarshal(w.Body.Bytes(), &got)
+			_ = json.Unmarshal([]byte(tt.wantBody), &want)
+			if !jsonEqual(got, want) {
+				t.Errorf("body = %s, want %s", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
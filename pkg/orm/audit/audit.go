@@ -0,0 +1,174 @@
+// Package audit tracks who writes the records: it fills the created_by and updated_by columns
+// with the actor of the context and, for the models implementing Audited, writes the changes
+// of every record to an audit table.
+package audit
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/orm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const PluginName = "monster:audit"
+
+// The columns filled with the actor of the context.
+const (
+	CreatedByColumn = "created_by"
+	UpdatedByColumn = "updated_by"
+)
+
+// DefaultTable is the audit table of the plugins made by New.
+var DefaultTable = "audit_logs"
+
+type actorKey struct{}
+
+// WithActor returns a context whose writes are made by actor, e.g. the id of the authenticated user.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor stored in ctx by WithActor.
+func Actor(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+// Actors holds the actor columns, embed it in a model to track who created and last updated its records.
+type Actors struct {
+	CreatedBy string `gorm:"size:128"`
+	UpdatedBy string `gorm:"size:128"`
+}
+
+// Audited is implemented by the models whose changes are written to the audit table,
+// its method only marks them.
+type Audited interface {
+	Audited()
+}
+
+// Action is the kind of write of a Record.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Record is a row of the audit table, the change of one record by one statement.
+type Record struct {
+	ID        uint64 `gorm:"primaryKey"`
+	TableName string `gorm:"column:table_name;size:128;index:idx_audit_record"`
+	RecordID  string `gorm:"size:128;index:idx_audit_record"`
+	Action    Action `gorm:"size:16"`
+	Actor     string `gorm:"size:128"`
+	Changes   string `gorm:"type:text"` // JSON object of the changed columns, {"column": {"old": ..., "new": ...}}
+	CreatedAt time.Time
+}
+
+// Change is the value of a column before and after a write.
+type Change struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// Plugin is the gorm plugin of the audit trail, the mysql and postgresql databases use it by default.
+//
+// The changes are written in the transaction of the write, so that they are rolled back with it,
+// unless the gorm config skips the default transaction. Only the writes made with the models are
+// audited, not the raw SQL statements.
+type Plugin struct {
+	Table string
+}
+
+func New() *Plugin {
+	return &Plugin{Table: DefaultTable}
+}
+
+// Register uses a new Plugin on the connections made with o, unless o uses one already.
+func Register(o *orm.Options) {
+	for _, plugin := range o.Plugins {
+		if plugin.Name() == PluginName {
+			return
+		}
+	}
+	o.Plugins = append([]gorm.Plugin{New()}, o.Plugins...)
+}
+
+// Migrate creates the audit table of the plugin, or adds its missing columns.
+func (p *Plugin) Migrate(db *gorm.DB) error {
+	return db.Table(p.Table).AutoMigrate(&Record{})
+}
+
+func (p *Plugin) Name() string {
+	return PluginName
+}
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	create := db.Callback().Create()
+	if err := create.After("gorm:before_create").Before("gorm:create").Register(PluginName+":actors", setActors(true)); err != nil {
+		return err
+	}
+	if err := create.After("gorm:create").Before("gorm:commit_or_rollback_transaction").Register(PluginName+":trail", p.afterCreate); err != nil {
+		return err
+	}
+
+	update := db.Callback().Update()
+	if err := update.After("gorm:before_update").Before("gorm:update").Register(PluginName+":actors", setActors(false)); err != nil {
+		return err
+	}
+	if err := update.After(PluginName+":actors").Before("gorm:update").Register(PluginName+":snapshot", p.snapshot); err != nil {
+		return err
+	}
+	if err := update.After("gorm:update").Before("gorm:commit_or_rollback_transaction").Register(PluginName+":trail", p.afterUpdate); err != nil {
+		return err
+	}
+
+	del := db.Callback().Delete()
+	if err := del.After("gorm:before_delete").Before("gorm:delete").Register(PluginName+":snapshot", p.snapshot); err != nil {
+		return err
+	}
+	return del.After("gorm:delete").Before("gorm:commit_or_rollback_transaction").Register(PluginName+":trail", p.afterDelete)
+}
+
+// setActors fills the actor columns with the actor of the context, created_by only on create
+// and when it is not set already.
+func setActors(create bool) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.Statement.Schema == nil {
+			return
+		}
+		actor, ok := Actor(db.Statement.Context)
+		if !ok {
+			return
+		}
+		if field := db.Statement.Schema.LookUpField(CreatedByColumn); field != nil && create {
+			setCreatedBy(db, field, actor)
+		}
+		if db.Statement.Schema.LookUpField(UpdatedByColumn) != nil {
+			db.Statement.SetColumn(UpdatedByColumn, actor, true)
+		}
+	}
+}
+
+func setCreatedBy(db *gorm.DB, field *schema.Field, actor string) {
+	ctx, rv := db.Statement.Context, db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Struct:
+		if _, zero := field.ValueOf(ctx, rv); zero {
+			db.Statement.SetColumn(CreatedByColumn, actor, true)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			elem := reflect.Indirect(rv.Index(i))
+			if _, zero := field.ValueOf(ctx, elem); zero {
+				_ = db.AddError(field.Set(ctx, elem, actor))
+			}
+		}
+	default:
+		db.Statement.SetColumn(CreatedByColumn, actor, true)
+	}
+}
@@ -0,0 +1,250 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-monsters/monster/pkg/orm"
+	"github.com/go-monsters/monster/pkg/orm/sqlite"
+	"gorm.io/gorm"
+)
+
+type account struct {
+	ID      int `gorm:"primaryKey"`
+	Name    string
+	Balance int
+	Actors
+}
+
+func (account) Audited() {}
+
+// note is not audited, only its actor columns are filled.
+type note struct {
+	ID   int `gorm:"primaryKey"`
+	Text string
+	Actors
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	ctx := context.Background()
+	plugin := New()
+	db, err := sqlite.Open(ctx, sqlite.Config{InMemory: true}, orm.WithPlugins(plugin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	conn := db.GetConnection(ctx)
+	if err := plugin.Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&account{}, &note{}); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func trail(t *testing.T, db *gorm.DB) []Record {
+	t.Helper()
+	var records []Record
+	if err := db.Table(DefaultTable).Order("id").Find(&records).Error; err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestActors(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(db *gorm.DB) error
+		want  Actors
+	}{
+		{
+			name: "created",
+			write: func(db *gorm.DB) error {
+				return db.WithContext(WithActor(context.Background(), "ann")).Create(&note{ID: 1}).Error
+			},
+			want: Actors{CreatedBy: "ann", UpdatedBy: "ann"},
+		},
+		{
+			name: "created by set",
+			write: func(db *gorm.DB) error {
+				return db.WithContext(WithActor(context.Background(), "ann")).Create(&note{ID: 1, Actors: Actors{CreatedBy: "import"}}).Error
+			},
+			want: Actors{CreatedBy: "import", UpdatedBy: "ann"},
+		},
+		{
+			name: "batch created",
+			write: func(db *gorm.DB) error {
+				return db.WithContext(WithActor(context.Background(), "ann")).Create(&[]note{{ID: 1}, {ID: 2}}).Error
+			},
+			want: Actors{CreatedBy: "ann", UpdatedBy: "ann"},
+		},
+		{
+			name: "updated",
+			write: func(db *gorm.DB) error {
+				if err := db.WithContext(WithActor(context.Background(), "ann")).Create(&note{ID: 1}).Error; err != nil {
+					return err
+				}
+				return db.WithContext(WithActor(context.Background(), "bob")).Model(&note{ID: 1}).Update("text", "x").Error
+			},
+			want: Actors{CreatedBy: "ann", UpdatedBy: "bob"},
+		},
+		{
+			name:  "no actor",
+			write: func(db *gorm.DB) error { return db.Create(&note{ID: 1}).Error },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			if err := tt.write(db); err != nil {
+				t.Fatal(err)
+			}
+			var got note
+			if err := db.First(&got, 1).Error; err != nil {
+				t.Fatal(err)
+			}
+			if got.Actors != tt.want {
+				t.Errorf("actors = %+v, want %+v", got.Actors, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrail(t *testing.T) {
+	ctx := WithActor(context.Background(), "ann")
+	type change struct {
+		action  Action
+		id      string
+		columns []string
+	}
+	tests := []struct {
+		name  string
+		write func(db *gorm.DB) error
+		want  []change
+	}{
+		{
+			name:  "created",
+			write: func(db *gorm.DB) error { return db.Create(&account{ID: 3, Name: "cid", Balance: 5}).Error },
+			want:  []change{{ActionCreate, "3", []string{"balance", "created_by", "id", "name", "updated_by"}}},
+		},
+		{
+			name:  "updated column",
+			write: func(db *gorm.DB) error { return db.Model(&account{ID: 1}).Update("balance", 20).Error },
+			want:  []change{{action: ActionUpdate, id: "1", columns: []string{"balance"}}},
+		},
+		{
+			name: "saved without change",
+			write: func(db *gorm.DB) error {
+				return db.Save(&account{ID: 1, Name: "ann", Balance: 10, Actors: Actors{"ann", "ann"}}).Error
+			},
+		},
+		{
+			name: "updated where",
+			write: func(db *gorm.DB) error {
+				if err := db.Create(&account{ID: 2, Name: "bob"}).Error; err != nil {
+					return err
+				}
+				return db.Model(&account{}).Where("balance < ?", 100).Update("balance", 50).Error
+			},
+			want: []change{
+				{ActionCreate, "2", []string{"created_by", "id", "name", "updated_by"}},
+				{ActionUpdate, "1", []string{"balance"}},
+				{ActionUpdate, "2", []string{"balance"}},
+			},
+		},
+		{
+			name:  "deleted",
+			write: func(db *gorm.DB) error { return db.Delete(&account{ID: 1}).Error },
+			want:  []change{{ActionDelete, "1", []string{"balance", "created_by", "id", "name", "updated_by"}}},
+		},
+		{
+			name:  "deleted nothing",
+			write: func(db *gorm.DB) error { return db.Delete(&account{ID: 9}).Error },
+		},
+		{
+			name: "rolled back",
+			write: func(db *gorm.DB) error {
+				_ = db.Transaction(func(tx *gorm.DB) error {
+					if err := tx.Model(&account{ID: 1}).Update("balance", 20).Error; err != nil {
+						return err
+					}
+					return errors.New("rolled back")
+				})
+				return nil
+			},
+		},
+		{
+			name:  "not audited",
+			write: func(db *gorm.DB) error { return db.Create(&note{ID: 1, Text: "x"}).Error },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t).WithContext(ctx)
+			if err := db.Create(&account{ID: 1, Name: "ann", Balance: 10}).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.write(db); err != nil {
+				t.Fatal(err)
+			}
+
+			// the first record is the one of the creation above
+			records := trail(t, db)[1:]
+			var got []change
+			for _, r := range records {
+				var changes map[string]Change
+				if err := json.Unmarshal([]byte(r.Changes), &changes); err != nil {
+					t.Fatal(err)
+				}
+				c := change{action: r.Action, id: r.RecordID}
+				for _, column := range []string{"balance", "created_by", "id", "name", "updated_by"} {
+					if _, ok := changes[column]; ok {
+						c.columns = append(c.columns, column)
+					}
+				}
+				if r.Actor != "ann" || r.TableName != "accounts" {
+					t.Errorf("record made by %s on %s, want ann on accounts", r.Actor, r.TableName)
+				}
+				got = append(got, c)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("trail = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateChanges(t *testing.T) {
+	db := newTestDB(t)
+	_ = db.Create(&account{ID: 1, Name: "ann", Balance: 10}).Error
+	_ = db.Model(&account{ID: 1}).Updates(map[string]interface{}{"balance": 0, "name": "anne"}).Error
+
+	records := trail(t, db)
+	var changes map[string]Change
+	_ = json.Unmarshal([]byte(records[len(records)-1].Changes), &changes)
+	want := map[string]Change{"balance": {Old: 10.0, New: 0.0}, "name": {Old: "ann", New: "anne"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	o := orm.NewOptions()
+	Register(&o)
+	Register(&o)
+	if len(o.Plugins) != 1 || o.Plugins[0].Name() != PluginName {
+		t.Errorf("plugins = %v, want a single audit plugin", o.Plugins)
+	}
+
+	custom := &Plugin{Table: "custom"}
+	o = orm.NewOptions(orm.WithPlugins(custom))
+	Register(&o)
+	if len(o.Plugins) != 1 || o.Plugins[0] != custom {
+		t.Errorf("plugins = %v, want the custom audit plugin", o.Plugins)
+	}
+}
@@ -0,0 +1,212 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// snapshotKey holds the records read before an update or a delete, in the instance of the statement.
+const snapshotKey = "monster:audit:snapshot"
+
+func audited(stmt *gorm.Statement) bool {
+	if stmt.Schema == nil || len(stmt.Schema.PrimaryFields) == 0 {
+		return false
+	}
+	_, ok := reflect.New(stmt.Schema.ModelType).Interface().(Audited)
+	return ok
+}
+
+// snapshot reads the records about to be updated or deleted.
+func (p *Plugin) snapshot(db *gorm.DB) {
+	if db.Error != nil || db.DryRun || !audited(db.Statement) {
+		return
+	}
+	exprs := conditions(db.Statement)
+	if len(exprs) == 0 && !db.AllowGlobalUpdate {
+		// gorm refuses the statement
+		return
+	}
+
+	tx := p.session(db).Model(reflect.New(db.Statement.Schema.ModelType).Interface()).Table(db.Statement.Table)
+	if db.Statement.Unscoped {
+		tx = tx.Unscoped()
+	}
+	if len(exprs) > 0 {
+		tx = tx.Clauses(clause.Where{Exprs: exprs})
+	}
+	rows := reflect.New(reflect.SliceOf(db.Statement.Schema.ModelType))
+	if err := tx.Find(rows.Interface()).Error; err != nil {
+		_ = db.AddError(merror.Wrap(err, "could not read the records before the write"))
+		return
+	}
+	db.InstanceSet(snapshotKey, rows.Elem())
+}
+
+func (p *Plugin) afterCreate(db *gorm.DB) {
+	if db.Error != nil || db.DryRun || !audited(db.Statement) {
+		return
+	}
+	var records []Record
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Struct:
+		records = p.appendRecord(records, db, ActionCreate, reflect.Value{}, rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			records = p.appendRecord(records, db, ActionCreate, reflect.Value{}, reflect.Indirect(rv.Index(i)))
+		}
+	}
+	p.write(db, records)
+}
+
+// afterUpdate reads again the records of the snapshot and writes their changes.
+func (p *Plugin) afterUpdate(db *gorm.DB) {
+	before, ok := p.snapshotOf(db)
+	if !ok {
+		return
+	}
+	_, values := schema.GetIdentityFieldValuesMap(db.Statement.Context, before, db.Statement.Schema.PrimaryFields)
+	column, queryValues := schema.ToQueryValues(db.Statement.Table, db.Statement.Schema.PrimaryFieldDBNames, values)
+	after := reflect.New(reflect.SliceOf(db.Statement.Schema.ModelType))
+	err := p.session(db).Unscoped().Table(db.Statement.Table).
+		Where(clause.IN{Column: column, Values: queryValues}).
+		Find(after.Interface()).Error
+	if err != nil {
+		_ = db.AddError(merror.Wrap(err, "could not read the records after the write"))
+		return
+	}
+
+	updated := make(map[string]reflect.Value, after.Elem().Len())
+	for i := 0; i < after.Elem().Len(); i++ {
+		row := after.Elem().Index(i)
+		updated[recordID(db, row)] = row
+	}
+	var records []Record
+	for i := 0; i < before.Len(); i++ {
+		row := before.Index(i)
+		if newRow, ok := updated[recordID(db, row)]; ok {
+			records = p.appendRecord(records, db, ActionUpdate, row, newRow)
+		}
+	}
+	p.write(db, records)
+}
+
+func (p *Plugin) afterDelete(db *gorm.DB) {
+	before, ok := p.snapshotOf(db)
+	if !ok {
+		return
+	}
+	var records []Record
+	for i := 0; i < before.Len(); i++ {
+		records = p.appendRecord(records, db, ActionDelete, before.Index(i), reflect.Value{})
+	}
+	p.write(db, records)
+}
+
+func (p *Plugin) snapshotOf(db *gorm.DB) (reflect.Value, bool) {
+	if db.Error != nil || db.DryRun {
+		return reflect.Value{}, false
+	}
+	v, ok := db.InstanceGet(snapshotKey)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	rows := v.(reflect.Value)
+	return rows, rows.Len() > 0
+}
+
+// appendRecord appends the record of the change from oldRow to newRow, an invalid row meaning
+// the record didn't exist before or doesn't exist anymore. Nothing is appended if no column changed.
+func (p *Plugin) appendRecord(records []Record, db *gorm.DB, action Action, oldRow, newRow reflect.Value) []Record {
+	ctx := db.Statement.Context
+	changes := make(map[string]Change)
+	for _, field := range db.Statement.Schema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		var change Change
+		oldZero, newZero := true, true
+		if oldRow.IsValid() {
+			change.Old, oldZero = field.ValueOf(ctx, oldRow)
+		}
+		if newRow.IsValid() {
+			change.New, newZero = field.ValueOf(ctx, newRow)
+		}
+		if oldZero && newZero || reflect.DeepEqual(change.Old, change.New) {
+			continue
+		}
+		changes[field.DBName] = change
+	}
+	if len(changes) == 0 {
+		return records
+	}
+
+	row := newRow
+	if !row.IsValid() {
+		row = oldRow
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		_ = db.AddError(merror.Wrap(err, "could not encode the changes of the record"))
+		return records
+	}
+	actor, _ := Actor(ctx)
+	return append(records, Record{
+		TableName: db.Statement.Table,
+		RecordID:  recordID(db, row),
+		Action:    action,
+		Actor:     actor,
+		Changes:   string(data),
+	})
+}
+
+// write inserts the records in the audit table, in the transaction of the write.
+func (p *Plugin) write(db *gorm.DB, records []Record) {
+	if len(records) == 0 {
+		return
+	}
+	if err := p.session(db).Table(p.Table).Create(&records).Error; err != nil {
+		_ = db.AddError(merror.Wrap(err, "could not write the audit trail"))
+	}
+}
+
+// session returns a new statement on the connection of db, which is the transaction of the write.
+func (p *Plugin) session(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true, SkipHooks: true})
+}
+
+// conditions are the ones gorm uses to write: the where clause and the primary keys of the model.
+func conditions(stmt *gorm.Statement) []clause.Expression {
+	var exprs []clause.Expression
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok {
+			exprs = append(exprs, where.Exprs...)
+		}
+	}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array:
+		_, values := schema.GetIdentityFieldValuesMap(stmt.Context, stmt.ReflectValue, stmt.Schema.PrimaryFields)
+		column, queryValues := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, values)
+		if len(queryValues) > 0 {
+			exprs = append(exprs, clause.IN{Column: column, Values: queryValues})
+		}
+	}
+	return exprs
+}
+
+// recordID joins the values of the primary keys of row.
+func recordID(db *gorm.DB, row reflect.Value) string {
+	ids := make([]string, len(db.Statement.Schema.PrimaryFields))
+	for i, field := range db.Statement.Schema.PrimaryFields {
+		value, _ := field.ValueOf(db.Statement.Context, row)
+		ids[i] = fmt.Sprint(value)
+	}
+	return strings.Join(ids, ",")
+}
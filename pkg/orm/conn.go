@@ -0,0 +1,88 @@
+package orm
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"gorm.io/gorm"
+)
+
+// Conn is the connection of an adapter, made on the first use and kept until Close.
+// The adapters embed it and only supply their dialector and address.
+type Conn struct {
+	name    string
+	dialect func(address string) gorm.Dialector
+	address string
+	options Options
+
+	mu sync.Mutex
+	db atomic.Pointer[gorm.DB]
+}
+
+// NewConn returns a connection to address with the dialector built by dialect,
+// name is the name of the database in the errors.
+func NewConn(name string, dialect func(address string) gorm.Dialector, address string, o Options) *Conn {
+	return &Conn{name: name, dialect: dialect, address: address, options: o}
+}
+
+// GetConnection returns the transaction of ctx if any, otherwise a session of the connection.
+// If it can't connect, the returned *gorm.DB carries the error and the next call tries again.
+func (c *Conn) GetConnection(ctx context.Context) *gorm.DB {
+	if tx := Tx(ctx, c); tx != nil {
+		return tx.WithContext(ctx)
+	}
+	db, err := c.Connect(ctx)
+	if err != nil {
+		return FailedConnection(ctx, err)
+	}
+	return Session(ctx, db)
+}
+
+// Connect connects if it is not connected, retrying as configured by the options, and returns the connection.
+func (c *Conn) Connect(ctx context.Context) (*gorm.DB, error) {
+	if db := c.db.Load(); db != nil {
+		return db, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if db := c.db.Load(); db != nil {
+		return db, nil
+	}
+
+	db, err := Connect(ctx, c.dialect, c.address, c.options)
+	if err != nil {
+		return nil, merror.Wrapf(err, "could not connect to the %s database", c.name)
+	}
+	c.db.Store(db)
+	return db, nil
+}
+
+func (c *Conn) Ping(ctx context.Context) error {
+	db, err := c.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return merror.Wrapf(sqlDB.PingContext(ctx), "could not ping the %s database", c.name)
+}
+
+// Close closes the connection pool, the next call of GetConnection connects again.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	db := c.db.Swap(nil)
+	if db == nil {
+		return nil
+	}
+	return merror.Wrapf(Close(db), "could not close the %s database", c.name)
+}
+
+// conn lets WithTx carry the transactions of the adapters for the Conn they embed.
+func (c *Conn) conn() *Conn {
+	return c
+}
@@ -9,3 +9,9 @@ import (
 type Database interface {
 	GetConnection(ctx context.Context) *gorm.DB
 }
+
+// Notification is a message received on a channel a database listens to.
+type Notification struct {
+	Channel string
+	Payload string
+}
@@ -0,0 +1,127 @@
+// Package leader elects a leader among the instances of a service with an advisory lock of their database,
+// e.g. to run a singleton worker.
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/logger"
+	"github.com/go-monsters/monster/pkg/logger/native"
+	"github.com/go-monsters/monster/pkg/orm"
+)
+
+var (
+	DefaultRetryInterval = 5 * time.Second // time between two attempts to take the leadership
+	DefaultCheckInterval = 5 * time.Second // time between two checks that the leadership is still held
+	DefaultUnlockTimeout = 5 * time.Second
+)
+
+var errLost = merror.Error("the leadership was lost")
+
+// Election campaigns for the leadership named name, which is held by the instance holding its lock.
+type Election struct {
+	locker orm.Locker
+	name   string
+	leader atomic.Bool
+
+	RetryInterval time.Duration
+	CheckInterval time.Duration
+	Logger        logger.Logger
+}
+
+func New(locker orm.Locker, name string) *Election {
+	return &Election{
+		locker:        locker,
+		name:          name,
+		RetryInterval: DefaultRetryInterval,
+		CheckInterval: DefaultCheckInterval,
+		Logger:        native.New(),
+	}
+}
+
+// IsLeader reports whether the election holds the leadership.
+func (e *Election) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns for the leadership until ctx is done and runs fn while it holds it.
+// The context of fn is canceled when ctx is done or the leadership is lost, Run then waits
+// for fn to return before releasing the leadership or campaigning again.
+//
+// Run returns ctx.Err() when ctx is done, or what fn returns if it returns while leading.
+func (e *Election) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	for {
+		lock, err := e.locker.TryLock(ctx, e.name)
+		switch {
+		case err == nil:
+			err = e.lead(ctx, lock, fn)
+			if !errors.Is(err, errLost) {
+				return err
+			}
+			e.Logger.Warn("leader: lost the leadership of %s", e.name)
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case !errors.Is(err, orm.ErrLockTaken):
+			e.Logger.Warn("leader: could not campaign for %s: %v", e.name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.RetryInterval):
+		}
+	}
+}
+
+// lead runs fn while checking the lock, it returns errLost if the lock is lost.
+func (e *Election) lead(ctx context.Context, lock *orm.Lock, fn func(ctx context.Context) error) error {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var lost atomic.Bool
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(e.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-leaderCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := lock.Check(leaderCtx); err != nil && leaderCtx.Err() == nil {
+				lost.Store(true)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	e.leader.Store(true)
+	e.Logger.Info("leader: took the leadership of %s", e.name)
+	err := fn(leaderCtx)
+	e.leader.Store(false)
+	cancel()
+	wg.Wait()
+
+	unlockCtx, cancelUnlock := context.WithTimeout(context.Background(), DefaultUnlockTimeout)
+	defer cancelUnlock()
+	if err := lock.Unlock(unlockCtx); err != nil {
+		e.Logger.Warn("leader: %v", err)
+	}
+
+	if lost.Load() {
+		return errLost
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
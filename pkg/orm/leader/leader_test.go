@@ -0,0 +1,234 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/orm"
+	"github.com/go-monsters/monster/pkg/orm/sqlite"
+)
+
+// memoryLocker emulates advisory locks on the connections of a sqlite database.
+type memoryLocker struct {
+	db orm.Database
+
+	mu    sync.Mutex
+	held  map[string]*sql.Conn
+	fails int32 // the next TryLock calls fail
+}
+
+func newMemoryLocker(t *testing.T) *memoryLocker {
+	t.Helper()
+	db, err := sqlite.Open(context.Background(), sqlite.Config{InMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return &memoryLocker{db: db, held: make(map[string]*sql.Conn)}
+}
+
+func (m *memoryLocker) Lock(ctx context.Context, name string) (*orm.Lock, error) {
+	for {
+		lock, err := m.TryLock(ctx, name)
+		if !errors.Is(err, orm.ErrLockTaken) {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (m *memoryLocker) TryLock(ctx context.Context, name string) (*orm.Lock, error) {
+	if atomic.AddInt32(&m.fails, -1) >= 0 {
+		return nil, errors.New("connection refused")
+	}
+	return orm.AcquireLock(ctx, m.db.GetConnection(ctx), name, func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.held[name] != nil {
+			return false, nil
+		}
+		m.held[name] = conn
+		return true, nil
+	}, func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.held, name)
+		return true, nil
+	})
+}
+
+// lose ends the session holding the lock name, as a database restart would.
+func (m *memoryLocker) lose(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = m.held[name].Raw(func(interface{}) error { return driver.ErrBadConn })
+	delete(m.held, name)
+}
+
+func (m *memoryLocker) isHeld(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.held[name] != nil
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Info(string, ...interface{})  {}
+func (discardLogger) Warn(string, ...interface{})  {}
+func (discardLogger) Error(string, ...interface{}) {}
+
+func newTestElection(locker orm.Locker) *Election {
+	e := New(locker, "worker")
+	e.RetryInterval = time.Millisecond
+	e.CheckInterval = time.Millisecond
+	e.Logger = discardLogger{}
+	return e
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRun(t *testing.T) {
+	errWorker := errors.New("worker failed")
+	tests := []struct {
+		name    string
+		fails   int32
+		fn      func(ctx context.Context) error
+		cancel  bool
+		wantErr error
+	}{
+		{name: "fn returns", fn: func(ctx context.Context) error { return nil }},
+		{name: "fn fails", fn: func(ctx context.Context) error { return errWorker }, wantErr: errWorker},
+		{name: "campaigns again after a failure", fails: 3, fn: func(ctx context.Context) error { return nil }},
+		{
+			name:    "canceled while leading",
+			fn:      func(ctx context.Context) error { <-ctx.Done(); return nil },
+			cancel:  true,
+			wantErr: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locker := newMemoryLocker(t)
+			locker.fails = tt.fails
+			e := newTestElection(locker)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var led atomic.Bool
+			done := make(chan error)
+			go func() {
+				done <- e.Run(ctx, func(ctx context.Context) error {
+					led.Store(e.IsLeader() && locker.isHeld("worker"))
+					return tt.fn(ctx)
+				})
+			}()
+			if tt.cancel {
+				waitFor(t, "the leadership", e.IsLeader)
+				cancel()
+			}
+			if err := <-done; !errors.Is(err, tt.wantErr) {
+				t.Errorf("Run error = %v, want %v", err, tt.wantErr)
+			}
+			if !led.Load() {
+				t.Error("fn ran without the leadership")
+			}
+			if e.IsLeader() || locker.isHeld("worker") {
+				t.Error("the leadership is held after Run returned")
+			}
+		})
+	}
+}
+
+func TestSingleLeader(t *testing.T) {
+	locker := newMemoryLocker(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var leaders, maxLeaders int32
+	var wg sync.WaitGroup
+	elections := make([]*Election, 3)
+	cancels := make([]context.CancelFunc, len(elections))
+	for i := range elections {
+		elections[i] = newTestElection(locker)
+		var electionCtx context.Context
+		electionCtx, cancels[i] = context.WithCancel(ctx)
+		wg.Add(1)
+		go func(e *Election, ctx context.Context) {
+			defer wg.Done()
+			_ = e.Run(ctx, func(ctx context.Context) error {
+				n := atomic.AddInt32(&leaders, 1)
+				if n > atomic.LoadInt32(&maxLeaders) {
+					atomic.StoreInt32(&maxLeaders, n)
+				}
+				<-ctx.Done()
+				atomic.AddInt32(&leaders, -1)
+				return nil
+			})
+		}(elections[i], electionCtx)
+	}
+
+	// the leader steps down when it is canceled, another instance takes over
+	leader := func() int {
+		for i, e := range elections {
+			if e.IsLeader() {
+				return i
+			}
+		}
+		return -1
+	}
+	waitFor(t, "a leader", func() bool { return leader() >= 0 })
+	first := leader()
+	cancels[first]()
+	waitFor(t, "a new leader", func() bool { return leader() >= 0 && leader() != first })
+
+	cancel()
+	wg.Wait()
+	if maxLeaders != 1 {
+		t.Errorf("%d leaders at once, want 1", maxLeaders)
+	}
+}
+
+func TestLostLeadership(t *testing.T) {
+	locker := newMemoryLocker(t)
+	e := newTestElection(locker)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var terms int32
+	done := make(chan error)
+	go func() {
+		done <- e.Run(ctx, func(ctx context.Context) error {
+			atomic.AddInt32(&terms, 1)
+			<-ctx.Done()
+			return nil
+		})
+	}()
+	waitFor(t, "the leadership", func() bool { return locker.isHeld("worker") })
+	locker.lose("worker")
+
+	// fn is canceled and the election campaigns again
+	waitFor(t, "a second term", func() bool { return atomic.LoadInt32(&terms) == 2 })
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run error = %v, want %v", err, context.Canceled)
+	}
+}
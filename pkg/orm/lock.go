@@ -0,0 +1,89 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"gorm.io/gorm"
+)
+
+var ErrLockTaken = merror.Error("the lock is held by another session")
+
+// Locker is implemented by the databases with advisory locks, mysql and postgresql.
+// The locks are held by a database session, they are released if it ends.
+type Locker interface {
+	// Lock takes the lock name, waiting for it until ctx is done.
+	Lock(ctx context.Context, name string) (*Lock, error)
+	// TryLock takes the lock name if it is free, otherwise it returns ErrLockTaken.
+	TryLock(ctx context.Context, name string) (*Lock, error)
+}
+
+// LockFunc runs the statement taking or releasing a lock on conn, it reports whether it succeeded.
+type LockFunc func(ctx context.Context, conn *sql.Conn) (bool, error)
+
+// Lock is an advisory lock, held by a connection dedicated to it until Unlock.
+type Lock struct {
+	name    string
+	conn    *sql.Conn
+	release LockFunc
+	once    sync.Once
+	err     error
+}
+
+// AcquireLock takes the lock name with acquire, on a connection of db kept for the lock until it is
+// released with release. The adapters implement Locker with it.
+func AcquireLock(ctx context.Context, db *gorm.DB, name string, acquire, release LockFunc) (*Lock, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, merror.Wrapf(err, "could not get a connection for the lock %s", name)
+	}
+	ok, err := acquire(ctx, conn)
+	if err != nil {
+		// the lock may have been taken after the failure
+		discard(conn)
+		return nil, merror.Wrapf(err, "could not take the lock %s", name)
+	}
+	if !ok {
+		_ = conn.Close()
+		return nil, merror.Wrap(ErrLockTaken, name)
+	}
+	return &Lock{name: name, conn: conn, release: release}, nil
+}
+
+func (l *Lock) Name() string {
+	return l.name
+}
+
+// Check reports an error if the connection holding the lock is lost, the lock may then be taken by another session.
+func (l *Lock) Check(ctx context.Context) error {
+	return merror.Wrapf(l.conn.PingContext(ctx), "the connection holding the lock %s is lost", l.name)
+}
+
+// Unlock releases the lock and its connection, it does nothing the next times.
+func (l *Lock) Unlock(ctx context.Context) error {
+	l.once.Do(func() {
+		if _, err := l.release(ctx, l.conn); err != nil {
+			// ending the session releases the lock
+			discard(l.conn)
+			l.err = merror.Wrapf(err, "could not release the lock %s", l.name)
+			return
+		}
+		l.err = l.conn.Close()
+	})
+	return l.err
+}
+
+// discard closes the session of conn instead of returning it to the pool, releasing its locks.
+func discard(conn *sql.Conn) {
+	_ = conn.Raw(func(interface{}) error {
+		return driver.ErrBadConn
+	})
+	_ = conn.Close()
+}
@@ -0,0 +1,77 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func lockFunc(ok bool, err error) LockFunc {
+	return func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		return ok, err
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	errLock := errors.New("lock failed")
+	tests := []struct {
+		name          string
+		acquire       LockFunc
+		release       LockFunc
+		wantErr       error
+		wantUnlockErr error
+	}{
+		{name: "taken", acquire: lockFunc(true, nil), release: lockFunc(true, nil)},
+		{name: "held by another session", acquire: lockFunc(false, nil), wantErr: ErrLockTaken},
+		{name: "acquire error", acquire: lockFunc(false, errLock), wantErr: errLock},
+		{name: "release error", acquire: lockFunc(true, nil), release: lockFunc(false, errLock), wantUnlockErr: errLock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestConn(t).GetConnection(ctx)
+			lock, err := AcquireLock(ctx, db, "jobs", tt.acquire, tt.release)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AcquireLock error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				// the connection went back to the pool or was discarded
+				if open := db.Statement.ConnPool.(*sql.DB).Stats().InUse; open != 0 {
+					t.Errorf("%d connections in use, want 0", open)
+				}
+				return
+			}
+			if lock.Name() != "jobs" {
+				t.Errorf("Name = %s, want jobs", lock.Name())
+			}
+			if err := lock.Check(ctx); err != nil {
+				t.Errorf("Check error = %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				if err := lock.Unlock(ctx); !errors.Is(err, tt.wantUnlockErr) {
+					t.Errorf("Unlock #%d error = %v, want %v", i+1, err, tt.wantUnlockErr)
+				}
+			}
+		})
+	}
+}
+
+func TestLockCheck(t *testing.T) {
+	ctx := context.Background()
+	var held *sql.Conn
+	acquire := func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		held = conn
+		return true, nil
+	}
+	lock, err := AcquireLock(ctx, newTestConn(t).GetConnection(ctx), "jobs", acquire, lockFunc(true, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the session holding the lock ends
+	_ = held.Raw(func(interface{}) error { return driver.ErrBadConn })
+	if err := lock.Check(ctx); err == nil {
+		t.Error("Check error = nil, want the lost connection reported")
+	}
+}
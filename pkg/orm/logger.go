@@ -0,0 +1,108 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/logger"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// DefaultSlowThreshold is the duration from which a query is logged as slow.
+var DefaultSlowThreshold = 200 * time.Millisecond
+
+type LoggerConfig struct {
+	SlowThreshold             time.Duration // 0 means DefaultSlowThreshold, negative disables the slow query warnings
+	LogLevel                  gormlogger.LogLevel
+	IgnoreRecordNotFoundError bool
+	// Redact logs the queries with their placeholders instead of the parameters,
+	// except the ones run by Scan, which gorm interpolates before the logger sees them.
+	Redact bool
+}
+
+// Logger routes the logs of gorm to a logger.Logger: the failed queries as errors, the slow queries
+// as warnings and, at the Info level, every query.
+type Logger struct {
+	logger logger.Logger
+	config LoggerConfig
+}
+
+func NewLogger(l logger.Logger, config LoggerConfig) *Logger {
+	if config.SlowThreshold == 0 {
+		config.SlowThreshold = DefaultSlowThreshold
+	}
+	if config.LogLevel == 0 {
+		config.LogLevel = gormlogger.Warn
+	}
+	return &Logger{logger: l, config: config}
+}
+
+// WithLogger logs through l instead of the default gorm logger writing to stdout.
+func WithLogger(l logger.Logger, config LoggerConfig) Option {
+	return func(o *Options) {
+		o.Logger = NewLogger(l, config)
+	}
+}
+
+func (l *Logger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	nl := *l
+	nl.config.LogLevel = level
+	return &nl
+}
+
+func (l *Logger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= gormlogger.Info {
+		l.logger.Info("gorm: "+msg, data...)
+	}
+}
+
+func (l *Logger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= gormlogger.Warn {
+		l.logger.Warn("gorm: "+msg, data...)
+	}
+}
+
+func (l *Logger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.config.LogLevel >= gormlogger.Error {
+		l.logger.Error("gorm: "+msg, data...)
+	}
+}
+
+func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.config.LogLevel <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.config.LogLevel >= gormlogger.Error &&
+		(!errors.Is(err, gorm.ErrRecordNotFound) || !l.config.IgnoreRecordNotFoundError):
+		sql, rows := fc()
+		l.logger.Error("gorm: query failed after %s, rows: %s, sql: %s, error: %s", elapsed, rowsString(rows), sql, err.Error())
+	case l.config.SlowThreshold > 0 && elapsed > l.config.SlowThreshold && l.config.LogLevel >= gormlogger.Warn:
+		sql, rows := fc()
+		l.logger.Warn("gorm: slow query took %s over %s, rows: %s, sql: %s", elapsed, l.config.SlowThreshold, rowsString(rows), sql)
+	case l.config.LogLevel >= gormlogger.Info:
+		sql, rows := fc()
+		l.logger.Info("gorm: query took %s, rows: %s, sql: %s", elapsed, rowsString(rows), sql)
+	}
+}
+
+// ParamsFilter is called by gorm before it interpolates the parameters into the logged query.
+func (l *Logger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.config.Redact {
+		return sql, nil
+	}
+	return sql, params
+}
+
+func rowsString(rows int64) string {
+	// -1 means the number of rows is unknown
+	if rows == -1 {
+		return "-"
+	}
+	return fmt.Sprint(rows)
+}
@@ -0,0 +1,167 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sqlite "go.elastic.co/apm/module/apmgormv2/v2/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// recordingLogger keeps the messages logged, prefixed by their level.
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) log(level, msg string, params ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, level+" "+fmt.Sprintf(msg, params...))
+}
+
+func (l *recordingLogger) Info(msg string, params ...interface{})  { l.log("info", msg, params...) }
+func (l *recordingLogger) Warn(msg string, params ...interface{})  { l.log("warn", msg, params...) }
+func (l *recordingLogger) Error(msg string, params ...interface{}) { l.log("error", msg, params...) }
+
+func (l *recordingLogger) all() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.messages, "\n")
+}
+
+func TestTrace(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name    string
+		config  LoggerConfig
+		elapsed time.Duration
+		err     error
+		want    string // level of the message, empty if nothing is logged
+	}{
+		{name: "fast query at warn", config: LoggerConfig{}, elapsed: time.Millisecond},
+		{name: "fast query at info", config: LoggerConfig{LogLevel: gormlogger.Info}, elapsed: time.Millisecond, want: "info gorm: query took"},
+		{name: "slow query", config: LoggerConfig{}, elapsed: time.Second, want: "warn gorm: slow query"},
+		{name: "slow query under a threshold", config: LoggerConfig{SlowThreshold: 2 * time.Second}, elapsed: time.Second},
+		{name: "slow warnings disabled", config: LoggerConfig{SlowThreshold: -1}, elapsed: time.Hour},
+		{name: "slow query at error", config: LoggerConfig{LogLevel: gormlogger.Error}, elapsed: time.Second},
+		{name: "failed query", config: LoggerConfig{}, err: errFailed, want: "error gorm: query failed"},
+		{name: "failed query when silent", config: LoggerConfig{LogLevel: gormlogger.Silent}, err: errFailed},
+		{name: "record not found", config: LoggerConfig{}, err: gorm.ErrRecordNotFound, want: "error gorm: query failed"},
+		{name: "record not found ignored", config: LoggerConfig{IgnoreRecordNotFoundError: true}, err: gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingLogger{}
+			l := NewLogger(rec, tt.config)
+			l.Trace(context.Background(), time.Now().Add(-tt.elapsed), func() (string, int64) {
+				return "SELECT 1", -1
+			}, tt.err)
+
+			got := rec.all()
+			if tt.want == "" && got != "" || !strings.HasPrefix(got, tt.want) {
+				t.Errorf("logged %q, want %q", got, tt.want)
+			}
+			if tt.want != "" && !strings.Contains(got, "rows: -, sql: SELECT 1") {
+				t.Errorf("logged %q, want the rows and the sql", got)
+			}
+		})
+	}
+}
+
+func TestLogMode(t *testing.T) {
+	tests := []struct {
+		level gormlogger.LogLevel
+		want  []string
+	}{
+		{level: gormlogger.Silent},
+		{level: gormlogger.Error, want: []string{"error"}},
+		{level: gormlogger.Warn, want: []string{"warn", "error"}},
+		{level: gormlogger.Info, want: []string{"info", "warn", "error"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.level), func(t *testing.T) {
+			rec := &recordingLogger{}
+			base := NewLogger(rec, LoggerConfig{LogLevel: gormlogger.Error})
+			l := base.LogMode(tt.level)
+			ctx := context.Background()
+			l.Info(ctx, "info")
+			l.Warn(ctx, "warn")
+			l.Error(ctx, "error")
+
+			var want []string
+			for _, level := range tt.want {
+				want = append(want, level+" gorm: "+level)
+			}
+			if got := rec.all(); got != strings.Join(want, "\n") {
+				t.Errorf("logged %q, want %q", got, want)
+			}
+			if base.config.LogLevel != gormlogger.Error {
+				t.Error("LogMode changed the level of the logger it was called on")
+			}
+		})
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	tests := []struct {
+		name   string
+		redact bool
+		opts   func(l Option) []Option
+		want   string
+		not    string
+	}{
+		{
+			name: "parameters",
+			opts: func(l Option) []Option { return []Option{l} },
+			want: "WHERE id = 42",
+		},
+		{
+			name:   "redacted",
+			redact: true,
+			opts:   func(l Option) []Option { return []Option{l} },
+			want:   "WHERE id = ?",
+			not:    "42",
+		},
+		{
+			name: "after the gorm config",
+			opts: func(l Option) []Option {
+				return []Option{WithGormConfig(&gorm.Config{Logger: gormlogger.Discard}), l}
+			},
+			want: "WHERE id = 42",
+		},
+		{
+			name: "before the gorm config",
+			opts: func(l Option) []Option {
+				return []Option{l, WithGormConfig(&gorm.Config{Logger: gormlogger.Discard})}
+			},
+			want: "WHERE id = 42",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingLogger{}
+			l := WithLogger(rec, LoggerConfig{LogLevel: gormlogger.Info, Redact: tt.redact})
+			db, err := Connect(context.Background(), sqlite.Open, filepath.Join(t.TempDir(), "test.db"), NewOptions(tt.opts(l)...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Close(db)
+			var ids []int
+			db.Exec("CREATE TABLE records (id INTEGER)")
+			db.Table("records").Where("id = ?", 42).Pluck("id", &ids)
+
+			got := rec.all()
+			if !strings.Contains(got, tt.want) || tt.not != "" && strings.Contains(got, tt.not) {
+				t.Errorf("logged %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
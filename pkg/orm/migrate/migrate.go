@@ -0,0 +1,334 @@
+package migrate
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/logger"
+	"github.com/go-monsters/monster/pkg/logger/native"
+	"github.com/go-monsters/monster/pkg/orm"
+	"gorm.io/gorm"
+)
+
+var (
+	DefaultTable       = "schema_migrations"
+	DefaultLockTimeout = 15 * time.Minute // a lock row not refreshed for this long is considered left by a crashed process
+	DefaultLockWait    = time.Minute
+
+	ErrLocked = merror.Error("the migrations are locked by another process")
+)
+
+// record is a row of the migrations table.
+type record struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	Checksum  string `gorm:"size:64"`
+	AppliedAt time.Time
+}
+
+// lock is the single row of the lock table, held while migrating on the databases without advisory locks.
+type lock struct {
+	ID       int `gorm:"primaryKey;autoIncrement:false"`
+	LockedAt time.Time
+}
+
+// Status is the state of a migration, known locally, applied or both.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Missing   bool // applied but unknown locally
+	Drifted   bool // applied with a different checksum
+}
+
+type Migrator struct {
+	db         orm.Database
+	migrations []Migration
+
+	Table       string
+	LockTimeout time.Duration
+	LockWait    time.Duration
+	DryRun      bool // log the migrations instead of applying them
+	Logger      logger.Logger
+}
+
+func New(db orm.Database, migrations ...Migration) *Migrator {
+	m := &Migrator{
+		db:          db,
+		Table:       DefaultTable,
+		LockTimeout: DefaultLockTimeout,
+		LockWait:    DefaultLockWait,
+		Logger:      native.New(),
+	}
+	m.Register(migrations...)
+	return m
+}
+
+func (m *Migrator) Register(migrations ...Migration) {
+	m.migrations = append(m.migrations, migrations...)
+	sortMigrations(m.migrations)
+}
+
+// Up applies the pending migrations in order, every migration in its own transaction.
+// It fails without applying anything if the applied migrations drifted.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(applied map[int64]record) error {
+		if err := m.checkDrift(applied); err != nil {
+			return err
+		}
+		for i := range m.migrations {
+			mig := &m.migrations[i]
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if m.DryRun {
+				m.logDryRun("apply", mig, mig.UpSQL)
+				continue
+			}
+			err := m.conn(ctx).Transaction(func(tx *gorm.DB) error {
+				if err := mig.up(ctx, tx); err != nil {
+					return err
+				}
+				return tx.Table(m.Table).Create(&record{
+					Version:   mig.Version,
+					Name:      mig.Name,
+					Checksum:  mig.Checksum(),
+					AppliedAt: time.Now(),
+				}).Error
+			})
+			if err != nil {
+				return merror.Wrapf(err, "could not apply the migration %d_%s", mig.Version, mig.Name)
+			}
+			m.Logger.Info("migrate: applied %d_%s", mig.Version, mig.Name)
+		}
+		return nil
+	})
+}
+
+// Down reverts the last steps applied migrations, in reverse order.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, func(applied map[int64]record) error {
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := &m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			steps--
+			if m.DryRun {
+				m.logDryRun("revert", mig, mig.DownSQL)
+				continue
+			}
+			err := m.conn(ctx).Transaction(func(tx *gorm.DB) error {
+				if err := mig.down(ctx, tx); err != nil {
+					return err
+				}
+				return tx.Table(m.Table).Where("version = ?", mig.Version).Delete(&record{}).Error
+			})
+			if err != nil {
+				return merror.Wrapf(err, "could not revert the migration %d_%s", mig.Version, mig.Name)
+			}
+			m.Logger.Info("migrate: reverted %d_%s", mig.Version, mig.Name)
+		}
+		return nil
+	})
+}
+
+// Status lists the known and the applied migrations ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	known := make(map[int64]bool, len(m.migrations))
+	for i := range m.migrations {
+		mig := &m.migrations[i]
+		known[mig.Version] = true
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if r, ok := applied[mig.Version]; ok {
+			s.Applied, s.AppliedAt = true, r.AppliedAt
+			s.Drifted = r.Checksum != mig.Checksum()
+		}
+		statuses = append(statuses, s)
+	}
+	for _, r := range applied {
+		if !known[r.Version] {
+			statuses = append(statuses, Status{
+				Version: r.Version, Name: r.Name, Applied: true, AppliedAt: r.AppliedAt, Missing: true,
+			})
+		}
+	}
+	sortStatuses(statuses)
+	return statuses, nil
+}
+
+// Drift returns an error describing the applied migrations which changed or disappeared locally.
+func (m *Migrator) Drift(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	return m.checkDrift(applied)
+}
+
+func (m *Migrator) checkDrift(applied map[int64]record) error {
+	known := make(map[int64]*Migration, len(m.migrations))
+	for i := range m.migrations {
+		known[m.migrations[i].Version] = &m.migrations[i]
+	}
+	for _, r := range applied {
+		mig, ok := known[r.Version]
+		if !ok {
+			return merror.Errorf("the applied migration %d_%s is unknown", r.Version, r.Name)
+		}
+		if mig.Checksum() != r.Checksum {
+			return merror.Errorf("the applied migration %d_%s was modified", r.Version, r.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) logDryRun(action string, mig *Migration, sql string) {
+	if sql == "" {
+		m.Logger.Info("migrate: would %s the Go migration %d_%s", action, mig.Version, mig.Name)
+		return
+	}
+	m.Logger.Info("migrate: would %s %d_%s\n%s", action, mig.Version, mig.Name, sql)
+}
+
+// applied reads the migrations table, none are applied if it doesn't exist.
+func (m *Migrator) applied(ctx context.Context) (map[int64]record, error) {
+	applied := make(map[int64]record)
+	if !m.conn(ctx).Migrator().HasTable(m.Table) {
+		return applied, nil
+	}
+	var records []record
+	if err := m.conn(ctx).Table(m.Table).Find(&records).Error; err != nil {
+		return nil, merror.Wrapf(err, "could not read the migrations table: %s", m.Table)
+	}
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// locked runs fn holding the migrations lock, so that a single process migrates at once.
+// A dry run changes nothing, it neither creates the tables nor takes the lock.
+func (m *Migrator) locked(ctx context.Context, fn func(applied map[int64]record) error) error {
+	if !m.DryRun {
+		unlock, err := m.lock(ctx)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if err := m.conn(ctx).Table(m.Table).AutoMigrate(&record{}); err != nil {
+			return merror.Wrapf(err, "could not create the migrations table: %s", m.Table)
+		}
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(applied)
+}
+
+// lock takes the advisory lock of the database if it has them (see orm.Locker), held as long as its
+// session, or else the lock row. It returns the function releasing the lock.
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	locker, ok := m.db.(orm.Locker)
+	if !ok {
+		return m.lockRow(ctx)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, m.LockWait)
+	defer cancel()
+	l, err := locker.Lock(waitCtx, m.Table+"_lock")
+	if err != nil {
+		if ctx.Err() == nil && waitCtx.Err() != nil {
+			return nil, merror.Wrap(ErrLocked, err.Error())
+		}
+		return nil, err
+	}
+	return func() {
+		if err := l.Unlock(context.Background()); err != nil {
+			m.Logger.Warn("migrate: %v", err)
+		}
+	}, nil
+}
+
+// lockRow inserts the lock row and refreshes it until it is released, so that it is only
+// considered stale after LockTimeout if the process holding it crashed.
+func (m *Migrator) lockRow(ctx context.Context) (func(), error) {
+	lockTable := m.Table + "_lock"
+	if err := m.conn(ctx).Table(lockTable).AutoMigrate(&lock{}); err != nil {
+		return nil, merror.Wrapf(err, "could not create the migrations lock table: %s", lockTable)
+	}
+
+	deadline := time.Now().Add(m.LockWait)
+	for {
+		err := m.conn(ctx).Table(lockTable).Where("locked_at < ?", time.Now().Add(-m.LockTimeout)).Delete(&lock{}).Error
+		if err != nil {
+			return nil, merror.Wrapf(err, "could not release the stale migrations lock: %s", lockTable)
+		}
+		err = m.conn(ctx).Table(lockTable).Create(&lock{ID: 1, LockedAt: time.Now()}).Error
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, merror.Wrap(ErrLocked, err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	interval := m.LockTimeout / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			err := m.conn(context.Background()).Table(lockTable).Where("id = ?", 1).Update("locked_at", time.Now()).Error
+			if err != nil {
+				m.Logger.Warn("migrate: could not refresh the migrations lock: %v", err)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+		if err := m.conn(context.Background()).Table(lockTable).Where("id = ?", 1).Delete(&lock{}).Error; err != nil {
+			m.Logger.Warn("migrate: could not release the migrations lock: %v", err)
+		}
+	}, nil
+}
+
+func (m *Migrator) conn(ctx context.Context) *gorm.DB {
+	return m.db.GetConnection(ctx).Session(&gorm.Session{NewDB: true})
+}
+
+func sortStatuses(statuses []Status) {
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+}
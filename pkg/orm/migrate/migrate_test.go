@@ -0,0 +1,284 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/orm/sqlite"
+	"gorm.io/gorm"
+)
+
+// recordingLogger keeps the messages logged by the migrator.
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Info(msg string, params ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(msg, params...))
+}
+
+func (l *recordingLogger) Warn(msg string, params ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(msg, params...))
+}
+
+func (l *recordingLogger) Error(msg string, params ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(msg, params...))
+}
+
+func newTestMigrator(t *testing.T, migrations ...Migration) (*Migrator, *recordingLogger) {
+	t.Helper()
+	db, err := sqlite.Open(context.Background(), sqlite.Config{InMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	l := &recordingLogger{}
+	m := New(db, migrations...)
+	m.Logger = l
+	return m, l
+}
+
+var testMigrations = []Migration{
+	{Version: 2, Name: "add_email", UpSQL: "ALTER TABLE users ADD COLUMN email TEXT", DownSQL: "ALTER TABLE users DROP COLUMN email"},
+	{Version: 1, Name: "create_users", UpSQL: "CREATE TABLE users (id INTEGER PRIMARY KEY)", DownSQL: "DROP TABLE users"},
+	{
+		Version: 3, Name: "seed",
+		Up: func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Exec("INSERT INTO users (id) VALUES (1)").Error
+		},
+		Down: func(ctx context.Context, tx *gorm.DB) error { return tx.Exec("DELETE FROM users").Error },
+	},
+}
+
+func applied(t *testing.T, m *Migrator) []int64 {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := []int64{}
+	for _, s := range statuses {
+		if s.Applied {
+			versions = append(versions, s.Version)
+		}
+	}
+	return versions
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []Migration
+		wantErr bool
+	}{
+		{
+			name: "up and down",
+			files: fstest.MapFS{
+				"2_b.up.sql":   {Data: []byte("UP 2")},
+				"1_a.up.sql":   {Data: []byte("UP 1")},
+				"1_a.down.sql": {Data: []byte("DOWN 1")},
+				"README.md":    {Data: []byte("ignored")},
+				"3_c.sql":      {Data: []byte("ignored")},
+			},
+			want: []Migration{
+				{Version: 1, Name: "a", UpSQL: "UP 1", DownSQL: "DOWN 1"},
+				{Version: 2, Name: "b", UpSQL: "UP 2"},
+			},
+		},
+		{name: "empty", files: fstest.MapFS{}, want: []Migration{}},
+		{name: "no up file", files: fstest.MapFS{"1_a.down.sql": {Data: []byte("DOWN")}}, wantErr: true},
+		{
+			name:    "same version",
+			files:   fstest.MapFS{"1_a.up.sql": {Data: []byte("UP")}, "1_b.up.sql": {Data: []byte("UP")}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	m := Migration{Version: 1, Name: "a", UpSQL: "UP"}
+	changed := m
+	changed.UpSQL = "UP;"
+	if m.Checksum() == changed.Checksum() {
+		t.Error("the checksum didn't change with the SQL")
+	}
+	if m.Checksum() != (&Migration{Version: 1, Name: "a", UpSQL: "UP"}).Checksum() {
+		t.Error("the checksum of the same migration changed")
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps int
+		want  []int64
+	}{
+		{name: "nothing reverted", steps: 0, want: []int64{1, 2, 3}},
+		{name: "last one reverted", steps: 1, want: []int64{1, 2}},
+		{name: "all reverted", steps: 3, want: []int64{}},
+		{name: "more steps than migrations", steps: 10, want: []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m, _ := newTestMigrator(t, testMigrations...)
+			if err := m.Up(ctx); err != nil {
+				t.Fatal(err)
+			}
+			// applying again is a no-op
+			if err := m.Up(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Down(ctx, tt.steps); err != nil {
+				t.Fatal(err)
+			}
+			if got := applied(t, m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applied = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpFailed(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMigrator(t,
+		Migration{Version: 1, Name: "create_users", UpSQL: "CREATE TABLE users (id INTEGER PRIMARY KEY)"},
+		Migration{Version: 2, Name: "broken", Up: func(ctx context.Context, tx *gorm.DB) error {
+			if err := tx.Exec("CREATE TABLE orders (id INTEGER)").Error; err != nil {
+				return err
+			}
+			return errors.New("broken")
+		}},
+	)
+	if err := m.Up(ctx); err == nil || !strings.Contains(err.Error(), "2_broken") {
+		t.Fatalf("Up error = %v, want the failed migration 2_broken", err)
+	}
+	if got := applied(t, m); !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("applied = %v, want [1]", got)
+	}
+	if m.conn(ctx).Migrator().HasTable("orders") {
+		t.Error("the failed migration was not rolled back")
+	}
+}
+
+func TestDownWithoutDownStep(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMigrator(t, Migration{Version: 1, Name: "create_users", UpSQL: "CREATE TABLE users (id INTEGER)"})
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Down(ctx, 1); err == nil {
+		t.Error("Down error = nil, want the missing down step")
+	}
+}
+
+func TestDrift(t *testing.T) {
+	tests := []struct {
+		name        string
+		migrations  []Migration
+		wantErr     bool
+		wantMissing bool
+		wantDrifted bool
+	}{
+		{name: "unchanged", migrations: testMigrations[1:2]},
+		{
+			name:        "modified",
+			migrations:  []Migration{{Version: 1, Name: "create_users", UpSQL: "CREATE TABLE users (id BIGINT PRIMARY KEY)"}},
+			wantErr:     true,
+			wantDrifted: true,
+		},
+		{name: "removed", wantErr: true, wantMissing: true},
+		{name: "new one pending", migrations: testMigrations[:2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m, _ := newTestMigrator(t, testMigrations[1])
+			if err := m.Up(ctx); err != nil {
+				t.Fatal(err)
+			}
+			m.migrations = nil
+			m.Register(tt.migrations...)
+
+			if err := m.Drift(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Drift error = %v, want error %v", err, tt.wantErr)
+			}
+			if err := m.Up(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Up error = %v, want error %v", err, tt.wantErr)
+			}
+			statuses, err := m.Status(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := statuses[0]; s.Version != 1 || s.Missing != tt.wantMissing || s.Drifted != tt.wantDrifted {
+				t.Errorf("Status = %+v, want missing %v and drifted %v", s, tt.wantMissing, tt.wantDrifted)
+			}
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	ctx := context.Background()
+	m, l := newTestMigrator(t, testMigrations...)
+	m.DryRun = true
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{m.Table, m.Table + "_lock", "users"} {
+		if m.conn(ctx).Migrator().HasTable(table) {
+			t.Errorf("the dry run created the table %s", table)
+		}
+	}
+	if len(l.messages) != 3 || !strings.Contains(l.messages[0], "CREATE TABLE users") || !strings.Contains(l.messages[2], "Go migration 3_seed") {
+		t.Errorf("logged %q, want the 3 migrations", l.messages)
+	}
+}
+
+func TestLockRow(t *testing.T) {
+	tests := []struct {
+		name     string
+		lockedAt time.Duration // age of the lock row left by another process
+		wantErr  error
+	}{
+		{name: "held by another process", lockedAt: time.Second, wantErr: ErrLocked},
+		{name: "left by a crashed process", lockedAt: 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m, _ := newTestMigrator(t, testMigrations...)
+			m.LockWait = 0
+			m.LockTimeout = time.Hour
+			lockTable := m.Table + "_lock"
+			_ = m.conn(ctx).Table(lockTable).AutoMigrate(&lock{})
+			_ = m.conn(ctx).Table(lockTable).Create(&lock{ID: 1, LockedAt: time.Now().Add(-tt.lockedAt)}).Error
+
+			if err := m.Up(ctx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Up error = %v, want %v", err, tt.wantErr)
+			}
+			var count int64
+			_ = m.conn(ctx).Table(lockTable).Count(&count)
+			if want := map[bool]int64{true: 1, false: 0}[tt.wantErr != nil]; count != want {
+				t.Errorf("%d lock rows, want %d", count, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"gorm.io/gorm"
+)
+
+// Func is a migration step written in Go, it runs inside the transaction of the migration.
+type Func func(ctx context.Context, tx *gorm.DB) error
+
+// Migration moves the schema from the previous version to Version with Up, and back with Down.
+// A step is either SQL or a Func, the SQL takes precedence.
+type Migration struct {
+	Version int64
+	Name    string
+	UpSQL   string
+	DownSQL string
+	Up      Func
+	Down    Func
+}
+
+// Checksum identifies the content of the migration, it is compared to the applied one to detect drift.
+// The checksum of a Go migration only covers its version and name.
+func (m *Migration) Checksum() string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s", m.Version, m.Name, m.UpSQL, m.DownSQL)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (m *Migration) up(ctx context.Context, tx *gorm.DB) error {
+	if m.UpSQL != "" {
+		return tx.Exec(m.UpSQL).Error
+	}
+	if m.Up != nil {
+		return m.Up(ctx, tx)
+	}
+	return nil
+}
+
+func (m *Migration) down(ctx context.Context, tx *gorm.DB) error {
+	if m.DownSQL != "" {
+		return tx.Exec(m.DownSQL).Error
+	}
+	if m.Down != nil {
+		return m.Down(ctx, tx)
+	}
+	return merror.Errorf("the migration %d_%s can't be reverted, it has no down step", m.Version, m.Name)
+}
+
+var fileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads the migrations from the files <version>_<name>.up.sql and <version>_<name>.down.sql of fsys,
+// the down file is optional. A file may hold several statements if the driver accepts them in one query,
+// which needs multiStatements=true in the DSN of mysql.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, merror.Wrap(err, "could not read the migrations directory")
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileRe.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, merror.Wrapf(err, "invalid migration version: %s", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, merror.Wrapf(err, "could not read the migration: %s", entry.Name())
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, merror.Errorf("two migrations have the version %d: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.UpSQL = string(content)
+		} else {
+			m.DownSQL = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpSQL == "" {
+			return nil, merror.Errorf("the migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sortMigrations(migrations)
+	return migrations, nil
+}
+
+// LoadDir reads the migrations of the directory, see Load.
+func LoadDir(dir string) ([]Migration, error) {
+	return Load(os.DirFS(dir))
+}
+
+// Create writes empty up and down files of a new migration to dir, versioned by the current time.
+func Create(dir, name string) (up, down string, err error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", "", merror.Wrapf(err, "could not create the migrations directory: %s", dir)
+	}
+	base := fmt.Sprintf("%s_%s", time.Now().UTC().Format("20060102150405"), name)
+	up = filepath.Join(dir, base+".up.sql")
+	down = filepath.Join(dir, base+".down.sql")
+	for _, fn := range []string{up, down} {
+		if err := os.WriteFile(fn, nil, 0644); err != nil {
+			return "", "", merror.Wrapf(err, "could not create the migration file: %s", fn)
+		}
+	}
+	return up, down, nil
+}
+
+func sortMigrations(migrations []Migration) {
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
@@ -0,0 +1,49 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+
+	"github.com/go-monsters/monster/pkg/orm"
+)
+
+// maxLockName is the maximum length of a lock name for GET_LOCK, longer names are hashed.
+const maxLockName = 64
+
+// Lock takes the advisory lock name with GET_LOCK, waiting for it until ctx is done.
+func (m *Mysql) Lock(ctx context.Context, name string) (*orm.Lock, error) {
+	return m.lock(ctx, name, -1)
+}
+
+// TryLock takes the advisory lock name with GET_LOCK if it is free, otherwise it returns orm.ErrLockTaken.
+func (m *Mysql) TryLock(ctx context.Context, name string) (*orm.Lock, error) {
+	return m.lock(ctx, name, 0)
+}
+
+// lock waits timeout seconds for the lock, a negative timeout meaning forever.
+func (m *Mysql) lock(ctx context.Context, name string, timeout int) (*orm.Lock, error) {
+	db, err := m.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := lockKey(name)
+	return orm.AcquireLock(ctx, db, name, func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		// 1 when taken, 0 on timeout and NULL on error
+		var taken sql.NullInt64
+		err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", key, timeout).Scan(&taken)
+		return taken.Valid && taken.Int64 == 1, err
+	}, func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", key)
+		return err == nil, err
+	})
+}
+
+func lockKey(name string) string {
+	if len(name) <= maxLockName {
+		return name
+	}
+	sum := sha1.Sum([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,25 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLockKey(t *testing.T) {
+	tests := []struct {
+		name string
+		lock string
+		want string
+	}{
+		{name: "short", lock: "jobs", want: "jobs"},
+		{name: "longest kept", lock: strings.Repeat("a", maxLockName), want: strings.Repeat("a", maxLockName)},
+		{name: "hashed", lock: strings.Repeat("a", maxLockName+1), want: "11655326c708d70319be2610e8a57d9a5b959d3b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lockKey(tt.lock); got != tt.want {
+				t.Errorf("lockKey = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,43 +2,72 @@ package mysql
 
 import (
 	"context"
-	"sync"
-	"time"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
 
 	"github.com/go-monsters/monster/pkg/orm"
+	"github.com/go-monsters/monster/pkg/orm/audit"
 	mysql "go.elastic.co/apm/module/apmgormv2/driver/mysql"
-	"gorm.io/gorm"
 )
 
+const defaultPort = 3306
+
 type Mysql struct {
-	address    string
-	dbConnOnce sync.Once
-	db         *gorm.DB
+	*orm.Conn
+}
+
+// New returns a database connecting on the first call of GetConnection with the default options.
+// If it can't connect, the returned *gorm.DB carries the error and the next call tries again.
+func New(address string, opts ...orm.Option) orm.Database {
+	return newMysql(address, opts)
 }
 
-func New(address string) orm.Database {
-	return &Mysql{
-		address: address,
+// Open connects to the database, retrying as configured by the options, and returns the error
+// if the connection can't be made.
+func Open(ctx context.Context, address string, opts ...orm.Option) (*Mysql, error) {
+	m := newMysql(address, opts)
+	if _, err := m.Connect(ctx); err != nil {
+		return nil, err
 	}
+	return m, nil
+}
+
+func newMysql(address string, opts []orm.Option) *Mysql {
+	return &Mysql{Conn: orm.NewConn("mysql", mysql.Open, address, newOptions(opts))}
+}
+
+// newOptions uses the audit plugin by default.
+func newOptions(opts []orm.Option) orm.Options {
+	o := orm.NewOptions(opts...)
+	audit.Register(&o)
+	return o
 }
 
-func (m *Mysql) GetConnection(ctx context.Context) *gorm.DB {
-	if m.db == nil {
-		m.dbConnOnce.Do(func() {
-			var err error
-			m.db, err = gorm.Open(mysql.Open(m.address), &gorm.Config{})
-			if err != nil {
-				panic(err)
-			}
-
-			db, err := m.db.DB()
-			if err != nil {
-				panic(err)
-			}
-			db.SetMaxIdleConns(10)
-			db.SetMaxOpenConns(20)
-			db.SetConnMaxLifetime(time.Hour)
-		})
+// DSN builds the address of the database from the config, in the format of go-sql-driver/mysql.
+func DSN(config orm.Config) string {
+	if config.DSN != "" {
+		return config.DSN
+	}
+	port := config.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	params := url.Values{}
+	for key, val := range config.Params {
+		params.Set(key, val)
 	}
-	return m.db.WithContext(ctx)
+	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s",
+		config.User, config.Password, net.JoinHostPort(config.Host, strconv.Itoa(port)), config.Database)
+	if len(params) > 0 {
+		dsn += "?" + params.Encode()
+	}
+	return dsn
+}
+
+func init() {
+	orm.RegisterDatabaseImpl("mysql", func(config orm.Config) (orm.Database, error) {
+		return New(DSN(config), config.Options()...), nil
+	})
 }
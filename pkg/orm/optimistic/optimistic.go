@@ -0,0 +1,138 @@
+// Package optimistic prevents the lost updates: a model carries a Version, and a write only applies
+// if the version of the row is still the one the model was read with.
+package optimistic
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var ErrStaleObject = merror.Error("the record was changed or deleted since it was read")
+
+// Version is the version of a record, add a field of this type to a model to lock it optimistically.
+// It starts at 0 and every write made with this package increments it.
+type Version int64
+
+var versionType = reflect.TypeOf(Version(0))
+
+// Save updates every column of model if its row still has the version of model,
+// otherwise it returns ErrStaleObject. The version of model is incremented on success.
+func Save(db *gorm.DB, model interface{}) error {
+	return update(db, model, model, true)
+}
+
+// Update updates the columns of values, a map or a model of the same type, like gorm Updates,
+// if the row of model still has the version of model, otherwise it returns ErrStaleObject.
+// The version of model is incremented on success, on ErrStaleObject model is to be read again.
+func Update(db *gorm.DB, model interface{}, values interface{}) error {
+	return update(db, model, values, false)
+}
+
+// Delete deletes the row of model if it still has the version of model, otherwise it returns ErrStaleObject.
+func Delete(db *gorm.DB, model interface{}) error {
+	stmt, field, current, err := version(db, model)
+	if err != nil {
+		return err
+	}
+	res := db.Where(versionIs(field, current)).Delete(model)
+	if res.Error != nil {
+		return merror.Wrap(res.Error, "could not delete the record")
+	}
+	if res.RowsAffected == 0 {
+		return stale(stmt, model, current)
+	}
+	return nil
+}
+
+func update(db *gorm.DB, model, values interface{}, all bool) error {
+	stmt, field, current, err := version(db, model)
+	if err != nil {
+		return err
+	}
+	next := current + 1
+
+	rv := reflect.Indirect(reflect.ValueOf(model))
+	switch v := values.(type) {
+	case map[string]interface{}:
+		withVersion := make(map[string]interface{}, len(v)+1)
+		for key, value := range v {
+			withVersion[key] = value
+		}
+		withVersion[field.DBName] = next
+		values = withVersion
+	default:
+		vv := reflect.Indirect(reflect.ValueOf(values))
+		if vv.Type() != rv.Type() {
+			return merror.Errorf("the values must be a map or a %s, got %T", rv.Type(), values)
+		}
+		if pv := reflect.ValueOf(values); pv.Kind() == reflect.Ptr && pv.Pointer() == reflect.ValueOf(model).Pointer() {
+			// restored below if the update doesn't apply
+			if err := field.Set(stmt.Context, rv, next); err != nil {
+				return err
+			}
+		} else {
+			copied := reflect.New(vv.Type())
+			copied.Elem().Set(vv)
+			if err := field.Set(stmt.Context, copied.Elem(), next); err != nil {
+				return err
+			}
+			values = copied.Interface()
+		}
+	}
+
+	tx := db.Model(model).Where(versionIs(field, current))
+	if all {
+		tx = tx.Select("*")
+	}
+	res := tx.Updates(values)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = stale(stmt, model, current)
+	}
+	if res.Error != nil {
+		_ = field.Set(stmt.Context, rv, current)
+		if errors.Is(res.Error, ErrStaleObject) {
+			return res.Error
+		}
+		return merror.Wrap(res.Error, "could not update the record")
+	}
+	return field.Set(stmt.Context, rv, next)
+}
+
+// version finds the Version field of model and its value, model must have its primary key set.
+func version(db *gorm.DB, model interface{}) (*gorm.Statement, *schema.Field, Version, error) {
+	stmt := &gorm.Statement{DB: db, Context: db.Statement.Context}
+	if err := stmt.Parse(model); err != nil {
+		return nil, nil, 0, merror.Wrap(err, "could not parse the model")
+	}
+	rv := reflect.Indirect(reflect.ValueOf(model))
+	if rv.Kind() != reflect.Struct || !rv.CanAddr() {
+		return nil, nil, 0, merror.Errorf("the model must be a pointer to a struct, got %T", model)
+	}
+	if pk := stmt.Schema.PrioritizedPrimaryField; pk == nil {
+		return nil, nil, 0, merror.Errorf("the model %s has no primary key", stmt.Schema.Name)
+	} else if _, zero := pk.ValueOf(stmt.Context, rv); zero {
+		return nil, nil, 0, merror.Errorf("the model %s has no primary key value", stmt.Schema.Name)
+	}
+
+	for _, field := range stmt.Schema.Fields {
+		if field.FieldType == versionType && field.DBName != "" {
+			value, _ := field.ValueOf(stmt.Context, rv)
+			return stmt, field, value.(Version), nil
+		}
+	}
+	return nil, nil, 0, merror.Errorf("the model %s has no Version field", stmt.Schema.Name)
+}
+
+func versionIs(field *schema.Field, v Version) clause.Expression {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: v}
+}
+
+func stale(stmt *gorm.Statement, model interface{}, v Version) error {
+	id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(stmt.Context, reflect.Indirect(reflect.ValueOf(model)))
+	return merror.Wrapf(ErrStaleObject, "%s %v at version %d", stmt.Schema.Table, id, v)
+}
@@ -0,0 +1,197 @@
+package optimistic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-monsters/monster/pkg/orm/sqlite"
+	"gorm.io/gorm"
+)
+
+type document struct {
+	ID      int `gorm:"primaryKey"`
+	Title   string
+	Body    string
+	Version Version
+}
+
+type unversioned struct {
+	ID    int `gorm:"primaryKey"`
+	Title string
+}
+
+// newDocument stores the document 1 at version 0, and returns it as read by two clients.
+func newDocument(t *testing.T) (*gorm.DB, *document, *document) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := sqlite.Open(ctx, sqlite.Config{InMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	conn := db.GetConnection(ctx)
+	if err := conn.AutoMigrate(&document{}, &unversioned{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Create(&document{ID: 1, Title: "draft", Body: "text"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var mine, theirs document
+	conn.First(&mine, 1)
+	conn.First(&theirs, 1)
+	return conn, &mine, &theirs
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name        string
+		write       func(db *gorm.DB, d *document) error
+		stale       bool // the other client saved first
+		wantErr     error
+		wantInvalid bool    // an error other than ErrStaleObject
+		wantVersion Version // of the model after the write
+		wantRow     *document
+	}{
+		{
+			name: "save",
+			write: func(db *gorm.DB, d *document) error {
+				d.Title = "final"
+				return Save(db, d)
+			},
+			wantVersion: 1,
+			wantRow:     &document{ID: 1, Title: "final", Body: "text", Version: 1},
+		},
+		{
+			name: "save zero values",
+			write: func(db *gorm.DB, d *document) error {
+				d.Body = ""
+				return Save(db, d)
+			},
+			wantVersion: 1,
+			wantRow:     &document{ID: 1, Title: "draft", Version: 1},
+		},
+		{
+			name: "save stale",
+			write: func(db *gorm.DB, d *document) error {
+				d.Title = "final"
+				return Save(db, d)
+			},
+			stale:       true,
+			wantErr:     ErrStaleObject,
+			wantVersion: 0,
+			wantRow:     &document{ID: 1, Title: "theirs", Body: "text", Version: 1},
+		},
+		{
+			name:        "update map",
+			write:       func(db *gorm.DB, d *document) error { return Update(db, d, map[string]interface{}{"title": "final"}) },
+			wantVersion: 1,
+			wantRow:     &document{ID: 1, Title: "final", Body: "text", Version: 1},
+		},
+		{
+			name:        "update map stale",
+			write:       func(db *gorm.DB, d *document) error { return Update(db, d, map[string]interface{}{"title": "final"}) },
+			stale:       true,
+			wantErr:     ErrStaleObject,
+			wantVersion: 0,
+			wantRow:     &document{ID: 1, Title: "theirs", Body: "text", Version: 1},
+		},
+		{
+			name:        "update struct",
+			write:       func(db *gorm.DB, d *document) error { return Update(db, d, document{Body: "new"}) },
+			wantVersion: 1,
+			wantRow:     &document{ID: 1, Title: "draft", Body: "new", Version: 1},
+		},
+		{
+			name:        "update other type",
+			write:       func(db *gorm.DB, d *document) error { return Update(db, d, unversioned{Title: "x"}) },
+			wantInvalid: true,
+			wantVersion: 0,
+			wantRow:     &document{ID: 1, Title: "draft", Body: "text"},
+		},
+		{
+			name:        "delete",
+			write:       func(db *gorm.DB, d *document) error { return Delete(db, d) },
+			wantVersion: 0,
+		},
+		{
+			name:        "delete stale",
+			write:       func(db *gorm.DB, d *document) error { return Delete(db, d) },
+			stale:       true,
+			wantErr:     ErrStaleObject,
+			wantVersion: 0,
+			wantRow:     &document{ID: 1, Title: "theirs", Body: "text", Version: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mine, theirs := newDocument(t)
+			if tt.stale {
+				theirs.Title = "theirs"
+				if err := Save(db, theirs); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := tt.write(db, mine)
+			if tt.wantInvalid {
+				if err == nil || errors.Is(err, ErrStaleObject) {
+					t.Fatalf("error = %v, want the invalid values", err)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if mine.Version != tt.wantVersion {
+				t.Errorf("model version = %d, want %d", mine.Version, tt.wantVersion)
+			}
+
+			var row document
+			err = db.First(&row, 1).Error
+			switch {
+			case tt.wantRow == nil && !errors.Is(err, gorm.ErrRecordNotFound):
+				t.Errorf("row = %+v, %v, want it deleted", row, err)
+			case tt.wantRow != nil && row != *tt.wantRow:
+				t.Errorf("row = %+v, want %+v", row, *tt.wantRow)
+			}
+		})
+	}
+}
+
+func TestWriteAgain(t *testing.T) {
+	db, mine, _ := newDocument(t)
+	for i := 1; i <= 3; i++ {
+		mine.Title = "edit"
+		if err := Save(db, mine); err != nil {
+			t.Fatalf("save %d error = %v", i, err)
+		}
+	}
+	if mine.Version != 3 {
+		t.Errorf("version = %d, want 3", mine.Version)
+	}
+	// a missing row is stale too
+	if err := Delete(db, &document{ID: 9}); !errors.Is(err, ErrStaleObject) {
+		t.Errorf("Delete of a missing row error = %v, want %v", err, ErrStaleObject)
+	}
+}
+
+func TestInvalidModel(t *testing.T) {
+	db, _, _ := newDocument(t)
+	tests := []struct {
+		name  string
+		model interface{}
+	}{
+		{name: "no version", model: &unversioned{ID: 1}},
+		{name: "no primary key value", model: &document{Title: "x"}},
+		{name: "not a pointer", model: document{ID: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Save(db, tt.model); err == nil || errors.Is(err, ErrStaleObject) {
+				t.Errorf("Save error = %v, want the invalid model", err)
+			}
+			if err := Delete(db, tt.model); err == nil || errors.Is(err, ErrStaleObject) {
+				t.Errorf("Delete error = %v, want the invalid model", err)
+			}
+		})
+	}
+}
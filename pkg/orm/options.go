@@ -0,0 +1,234 @@
+package orm
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// Options configures the connection pool of a Database and how it connects.
+type Options struct {
+	MaxIdleConns    int
+	MaxOpenConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration // 0 means connections are not closed for being idle
+	ConnectRetries  int           // attempts after the first failed one
+	RetryBackoff    time.Duration // wait before the first retry, doubled after every retry
+	GormConfig      *gorm.Config
+	Logger          logger.Interface // replaces the logger of GormConfig
+
+	Replicas            []string      // addresses of the read replicas
+	HealthCheckInterval time.Duration // time between two pings of every replica
+
+	Plugins []gorm.Plugin // used on every connection, after the replicas are set up
+}
+
+type Option func(*Options)
+
+func DefaultOptions() Options {
+	return Options{
+		MaxIdleConns:    10,
+		MaxOpenConns:    20,
+		ConnMaxLifetime: time.Hour,
+		RetryBackoff:    time.Second,
+	}
+}
+
+func NewOptions(opts ...Option) Options {
+	o := DefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func WithMaxIdleConns(n int) Option {
+	return func(o *Options) {
+		o.MaxIdleConns = n
+	}
+}
+
+func WithMaxOpenConns(n int) Option {
+	return func(o *Options) {
+		o.MaxOpenConns = n
+	}
+}
+
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(o *Options) {
+		o.ConnMaxLifetime = d
+	}
+}
+
+func WithConnMaxIdleTime(d time.Duration) Option {
+	return func(o *Options) {
+		o.ConnMaxIdleTime = d
+	}
+}
+
+func WithConnectRetries(retries int, backoff time.Duration) Option {
+	return func(o *Options) {
+		o.ConnectRetries = retries
+		o.RetryBackoff = backoff
+	}
+}
+
+// WithReplicas balances the reads between the replicas, unhealthy replicas are skipped
+// and the primary serves the reads when no replica is healthy.
+func WithReplicas(addresses ...string) Option {
+	return func(o *Options) {
+		o.Replicas = append(o.Replicas, addresses...)
+	}
+}
+
+func WithHealthCheckInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.HealthCheckInterval = d
+	}
+}
+
+// WithPlugins uses the gorm plugins on the connection, such as the query cache.
+func WithPlugins(plugins ...gorm.Plugin) Option {
+	return func(o *Options) {
+		o.Plugins = append(o.Plugins, plugins...)
+	}
+}
+
+func WithGormConfig(config *gorm.Config) Option {
+	return func(o *Options) {
+		o.GormConfig = config
+	}
+}
+
+// Connect opens a gorm connection to address with the dialector built by dialect, retrying with backoff
+// as configured by o, and sets up the connection pool and the replicas.
+func Connect(ctx context.Context, dialect func(address string) gorm.Dialector, address string, o Options) (*gorm.DB, error) {
+	backoff := o.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		db, err := connect(ctx, dialect(address), o)
+		if err == nil && len(o.Replicas) > 0 {
+			if err = useReplicas(db, dialect, o); err != nil {
+				closeDB(db)
+			}
+		}
+		if err == nil {
+			err = usePlugins(db, o.Plugins)
+		}
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= o.ConnectRetries {
+			return nil, merror.Wrapf(err, "could not connect to the database after %d attempts", attempt+1)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, merror.Wrap(ctx.Err(), "gave up connecting to the database")
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func connect(ctx context.Context, dialector gorm.Dialector, o Options) (*gorm.DB, error) {
+	config := gorm.Config{}
+	if o.GormConfig != nil {
+		config = *o.GormConfig
+	}
+	if o.Logger != nil {
+		config.Logger = o.Logger
+	}
+	// pinged below with ctx, the replicas opened with this config are pinged by their health check
+	config.DisableAutomaticPing = true
+	db, err := gorm.Open(dialector, &config)
+	if err != nil {
+		closeDB(db)
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+	sqlDB.SetMaxIdleConns(o.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(o.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(o.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(o.ConnMaxIdleTime)
+	return db, nil
+}
+
+func usePlugins(db *gorm.DB, plugins []gorm.Plugin) error {
+	for _, plugin := range plugins {
+		if err := db.Use(plugin); err != nil {
+			_ = Close(db)
+			return merror.Wrapf(err, "could not use the plugin %s", plugin.Name())
+		}
+	}
+	return nil
+}
+
+// FailedConnection returns a *gorm.DB carrying err, so that every query made with it returns err.
+// It lets GetConnection report a connection failure without panicking.
+func FailedConnection(ctx context.Context, err error) *gorm.DB {
+	db, _ := gorm.Open(failedDialector{}, &gorm.Config{Logger: logger.Discard})
+	db = db.WithContext(ctx)
+	_ = db.AddError(err)
+	return db
+}
+
+// failedDialector lets gorm build statements without a connection, they are never executed
+// since the connection carries an error.
+type failedDialector struct{}
+
+func (failedDialector) Name() string {
+	return "failed"
+}
+
+func (failedDialector) Initialize(db *gorm.DB) error {
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{})
+	return nil
+}
+
+func (d failedDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return migrator.Migrator{Config: migrator.Config{DB: db, Dialector: d}}
+}
+
+func (failedDialector) DataTypeOf(*schema.Field) string {
+	return ""
+}
+
+func (failedDialector) DefaultValueOf(*schema.Field) clause.Expression {
+	return clause.Expr{SQL: "DEFAULT"}
+}
+
+func (failedDialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	_ = writer.WriteByte('?')
+}
+
+func (failedDialector) QuoteTo(writer clause.Writer, str string) {
+	_, _ = writer.WriteString(str)
+}
+
+func (failedDialector) Explain(sql string, vars ...interface{}) string {
+	return sql
+}
+
+func closeDB(db *gorm.DB) {
+	if db == nil || db.ConnPool == nil {
+		return
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+}
@@ -0,0 +1,160 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sqlite "go.elastic.co/apm/module/apmgormv2/v2/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// unreachable is a sqlite file in a missing directory, opening it fails on the first ping.
+func unreachable(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "missing", "test.db")
+}
+
+func TestNewOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want func(o Options) bool
+	}{
+		{name: "defaults", want: func(o Options) bool {
+			return o.MaxIdleConns == 10 && o.MaxOpenConns == 20 && o.ConnMaxLifetime == time.Hour && o.RetryBackoff == time.Second
+		}},
+		{name: "pool", opts: []Option{WithMaxIdleConns(1), WithMaxOpenConns(2), WithConnMaxLifetime(time.Minute), WithConnMaxIdleTime(time.Second)}, want: func(o Options) bool {
+			return o.MaxIdleConns == 1 && o.MaxOpenConns == 2 && o.ConnMaxLifetime == time.Minute && o.ConnMaxIdleTime == time.Second
+		}},
+		{name: "retries", opts: []Option{WithConnectRetries(3, time.Millisecond)}, want: func(o Options) bool {
+			return o.ConnectRetries == 3 && o.RetryBackoff == time.Millisecond
+		}},
+		{name: "replicas are appended", opts: []Option{WithReplicas("a"), WithReplicas("b", "c")}, want: func(o Options) bool {
+			return strings.Join(o.Replicas, ",") == "a,b,c"
+		}},
+		{name: "last option wins", opts: []Option{WithMaxOpenConns(2), WithMaxOpenConns(5)}, want: func(o Options) bool {
+			return o.MaxOpenConns == 5
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if o := NewOptions(tt.opts...); !tt.want(o) {
+				t.Errorf("NewOptions = %+v", o)
+			}
+		})
+	}
+}
+
+func TestConnect(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int // attempts failing before the address is reachable
+		retries      int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "first attempt", wantAttempts: 1},
+		{name: "no retry", failures: 1, wantErr: true, wantAttempts: 1},
+		{name: "retried until connected", failures: 2, retries: 3, wantAttempts: 3},
+		{name: "retries exhausted", failures: 5, retries: 2, wantErr: true, wantAttempts: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			good, bad := filepath.Join(t.TempDir(), "test.db"), unreachable(t)
+			var attempts int
+			dialect := func(address string) gorm.Dialector {
+				attempts++
+				if attempts <= tt.failures {
+					return sqlite.Open(bad)
+				}
+				return sqlite.Open(address)
+			}
+
+			db, err := Connect(context.Background(), dialect, good, NewOptions(WithConnectRetries(tt.retries, time.Millisecond)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Connect error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				defer Close(db)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestConnectCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	address := unreachable(t)
+	_, err := Connect(ctx, sqlite.Open, address, NewOptions(WithConnectRetries(10, time.Hour)))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Connect error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestFailedConnection(t *testing.T) {
+	errDown := errors.New("database down")
+	db := FailedConnection(context.Background(), errDown)
+
+	var count int64
+	if err := db.Table("records").Count(&count).Error; !errors.Is(err, errDown) {
+		t.Errorf("Count error = %v, want %v", err, errDown)
+	}
+	if err := db.Exec("DELETE FROM records").Error; !errors.Is(err, errDown) {
+		t.Errorf("Exec error = %v, want %v", err, errDown)
+	}
+}
+
+func TestConn(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	var dials int32
+	var down atomic.Bool
+	down.Store(true)
+	c := NewConn("test", func(address string) gorm.Dialector {
+		atomic.AddInt32(&dials, 1)
+		if down.Load() {
+			return sqlite.Open(unreachable(t))
+		}
+		return sqlite.Open(address)
+	}, path, NewOptions())
+
+	if err := c.GetConnection(ctx).Exec("CREATE TABLE records (id INTEGER)").Error; err == nil {
+		t.Fatal("Exec on an unreachable database error = nil")
+	}
+	if err := c.Ping(ctx); err == nil || !strings.Contains(err.Error(), "test database") {
+		t.Errorf("Ping error = %v, want the failed connection to the test database", err)
+	}
+
+	// the next call connects again
+	down.Store(false)
+	if err := c.GetConnection(ctx).Exec("CREATE TABLE records (id INTEGER)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	before := atomic.LoadInt32(&dials)
+	_ = c.GetConnection(ctx).Exec("INSERT INTO records VALUES (1)")
+	if after := atomic.LoadInt32(&dials); after != before {
+		t.Errorf("%d dials for a connected Conn, want none", after-before)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close error = %v", err)
+	}
+	var count int64
+	if err := c.GetConnection(ctx).Table("records").Count(&count).Error; err != nil || count != 1 {
+		t.Errorf("Count after Close = %d, %v, want 1 record read on a new connection", count, err)
+	}
+	_ = c.Close()
+}
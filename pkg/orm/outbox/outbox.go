@@ -0,0 +1,86 @@
+// Package outbox publishes events reliably: the events are written to an outbox table in the transaction
+// of the business writes, then a Relay publishes them and marks them delivered. An event is published
+// at least once, and the events of an aggregate in the order they were added.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/orm"
+	"gorm.io/gorm"
+)
+
+var (
+	DefaultTable   = "outbox_events"
+	DefaultChannel = "outbox_events" // the channel notified on postgresql when events are added
+)
+
+// Event is a row of the outbox table.
+type Event struct {
+	ID            uint64 `gorm:"primaryKey"`
+	AggregateType string `gorm:"size:128;index:idx_outbox_aggregate"`
+	AggregateID   string `gorm:"size:128;index:idx_outbox_aggregate"`
+	Type          string `gorm:"size:128"`
+	Payload       []byte
+	CreatedAt     time.Time
+	Attempts      int
+	NextAttemptAt time.Time  `gorm:"index"` // also leases the event to the relay publishing it
+	LastError     string     `gorm:"size:1024"`
+	DeliveredAt   *time.Time `gorm:"index"`
+}
+
+// Outbox writes the events to the outbox table of a database.
+type Outbox struct {
+	db orm.Database
+
+	Table   string
+	Channel string
+}
+
+func New(db orm.Database) *Outbox {
+	return &Outbox{db: db, Table: DefaultTable, Channel: DefaultChannel}
+}
+
+// Migrate creates the outbox table, or adds its missing columns.
+func (o *Outbox) Migrate(ctx context.Context) error {
+	return merror.Wrap(o.conn(ctx).AutoMigrate(&Event{}), "could not migrate the outbox table")
+}
+
+// Add writes the events to the outbox, in the transaction of ctx if any (see orm.WithTx),
+// so that they are published only if the transaction commits.
+func (o *Outbox) Add(ctx context.Context, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range events {
+		events[i].ID = 0
+		events[i].Attempts = 0
+		events[i].NextAttemptAt = now
+		events[i].DeliveredAt = nil
+	}
+
+	conn := o.conn(ctx)
+	if err := conn.Create(&events).Error; err != nil {
+		return merror.Wrap(err, "could not add the events to the outbox")
+	}
+	// The relays listening are notified when the transaction commits.
+	if conn.Dialector.Name() == "postgres" {
+		if err := conn.Exec("SELECT pg_notify(?, '')", o.Channel).Error; err != nil {
+			return merror.Wrap(err, "could not notify the outbox relays")
+		}
+	}
+	return nil
+}
+
+// Purge deletes the events delivered before t.
+func (o *Outbox) Purge(ctx context.Context, t time.Time) (int64, error) {
+	res := o.conn(ctx).Where("delivered_at < ?", t).Delete(&Event{})
+	return res.RowsAffected, merror.Wrap(res.Error, "could not purge the outbox")
+}
+
+func (o *Outbox) conn(ctx context.Context) *gorm.DB {
+	return o.db.GetConnection(ctx).Table(o.Table)
+}
@@ -0,0 +1,279 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/orm"
+	"github.com/go-monsters/monster/pkg/orm/sqlite"
+)
+
+// recorder publishes the events in memory, failing the ones of the types in fail.
+type recorder struct {
+	mu        sync.Mutex
+	published []string
+	fail      map[string]bool
+}
+
+func (r *recorder) Publish(ctx context.Context, event Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail[event.Type] {
+		return errors.New("broker down")
+	}
+	r.published = append(r.published, event.Type)
+	return nil
+}
+
+func (r *recorder) events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.published...)
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Info(string, ...interface{})  {}
+func (discardLogger) Warn(string, ...interface{})  {}
+func (discardLogger) Error(string, ...interface{}) {}
+
+func newTestOutbox(t *testing.T) (*Outbox, orm.Database) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := sqlite.Open(ctx, sqlite.Config{InMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	o := New(db)
+	if err := o.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return o, db
+}
+
+func newTestRelay(o *Outbox, p Publisher) *Relay {
+	r := NewRelay(o, p)
+	r.Backoff = time.Millisecond
+	r.MaxBackoff = time.Millisecond
+	r.Logger = discardLogger{}
+	return r
+}
+
+func event(aggregate, typ string) Event {
+	return Event{AggregateType: "order", AggregateID: aggregate, Type: typ, Payload: []byte(`{}`)}
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name  string
+		txErr error
+		want  int64
+	}{
+		{name: "committed", want: 2},
+		{name: "rolled back", txErr: errors.New("failed")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, db := newTestOutbox(t)
+			ctx := context.Background()
+			delivered := time.Now()
+			err := orm.WithTx(ctx, db, func(ctx context.Context) error {
+				// the fields of the relay are reset
+				stale := event("1", "created")
+				stale.ID, stale.Attempts, stale.DeliveredAt = 42, 3, &delivered
+				if err := o.Add(ctx, stale, event("1", "paid")); err != nil {
+					return err
+				}
+				return tt.txErr
+			})
+			if !errors.Is(err, tt.txErr) {
+				t.Fatalf("WithTx error = %v, want %v", err, tt.txErr)
+			}
+
+			var events []Event
+			_ = o.conn(ctx).Order("id").Find(&events)
+			if int64(len(events)) != tt.want {
+				t.Fatalf("%d events, want %d", len(events), tt.want)
+			}
+			for _, e := range events {
+				if e.ID == 42 || e.Attempts != 0 || e.DeliveredAt != nil || e.NextAttemptAt.IsZero() {
+					t.Errorf("event added as %+v, want a new undelivered event", e)
+				}
+			}
+		})
+	}
+}
+
+func TestRelayOnce(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		fail   map[string]bool
+		rounds int
+		want   []string
+	}{
+		{
+			name:   "oldest event of every aggregate",
+			events: []Event{event("1", "1-created"), event("1", "1-paid"), event("2", "2-created")},
+			rounds: 1,
+			want:   []string{"1-created", "2-created"},
+		},
+		{
+			name:   "aggregates in order",
+			events: []Event{event("1", "1-created"), event("2", "2-created"), event("1", "1-paid"), event("1", "1-shipped")},
+			rounds: 3,
+			want:   []string{"1-created", "2-created", "1-paid", "1-shipped"},
+		},
+		{
+			name:   "failed event holds back its aggregate",
+			events: []Event{event("1", "1-created"), event("1", "1-paid"), event("2", "2-created"), event("2", "2-paid")},
+			fail:   map[string]bool{"1-created": true},
+			rounds: 3,
+			want:   []string{"2-created", "2-paid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, _ := newTestOutbox(t)
+			ctx := context.Background()
+			if err := o.Add(ctx, tt.events...); err != nil {
+				t.Fatal(err)
+			}
+			p := &recorder{fail: tt.fail}
+			r := newTestRelay(o, p)
+			for i := 0; i < tt.rounds; i++ {
+				time.Sleep(2 * time.Millisecond) // past the backoff of the failed events
+				if _, err := r.RelayOnce(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := p.events(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("published %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	o, _ := newTestOutbox(t)
+	ctx := context.Background()
+	_ = o.Add(ctx, event("1", "created"), event("1", "paid"))
+	p := &recorder{fail: map[string]bool{"created": true}}
+	r := newTestRelay(o, p)
+
+	for i := 0; i < 2; i++ {
+		time.Sleep(2 * time.Millisecond)
+		_, _ = r.RelayOnce(ctx)
+	}
+	var failed Event
+	_ = o.conn(ctx).First(&failed, "type = ?", "created")
+	if failed.Attempts != 2 || failed.LastError != "broker down" || failed.DeliveredAt != nil {
+		t.Errorf("failed event = %+v, want 2 attempts and its last error", failed)
+	}
+
+	// the broker is back
+	p.mu.Lock()
+	p.fail = nil
+	p.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		time.Sleep(2 * time.Millisecond)
+		_, _ = r.RelayOnce(ctx)
+	}
+	if got := p.events(); !reflect.DeepEqual(got, []string{"created", "paid"}) {
+		t.Errorf("published %v, want [created paid]", got)
+	}
+	var delivered Event
+	_ = o.conn(ctx).First(&delivered, "type = ?", "created")
+	if delivered.Attempts != 3 || delivered.LastError != "" || delivered.DeliveredAt == nil {
+		t.Errorf("delivered event = %+v, want delivered at the third attempt", delivered)
+	}
+}
+
+func TestLease(t *testing.T) {
+	o, _ := newTestOutbox(t)
+	ctx := context.Background()
+	_ = o.Add(ctx, event("1", "created"))
+	var e Event
+	_ = o.conn(ctx).First(&e)
+
+	// another relay leased the event after this one found it
+	_ = o.conn(ctx).Where("id = ?", e.ID).Update("next_attempt_at", time.Now().Add(time.Minute))
+	p := &recorder{}
+	if err := newTestRelay(o, p).relay(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.events(); len(got) != 0 {
+		t.Errorf("published %v, want the leased event left to the other relay", got)
+	}
+	if n, _ := newTestRelay(o, p).RelayOnce(ctx); n != 0 {
+		t.Errorf("RelayOnce found %d events, want the leased event skipped", n)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	r := &Relay{Backoff: time.Second, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Second},
+		{attempts: 1, want: 2 * time.Second},
+		{attempts: 3, want: 8 * time.Second},
+		{attempts: 4, want: 10 * time.Second},
+		{attempts: 100, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.attempts), func(t *testing.T) {
+			if got := r.backoff(tt.attempts); got != tt.want {
+				t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPurge(t *testing.T) {
+	o, _ := newTestOutbox(t)
+	ctx := context.Background()
+	_ = o.Add(ctx, event("1", "created"), event("2", "created"), event("3", "created"))
+	old, recent := time.Now().Add(-2*time.Hour), time.Now()
+	_ = o.conn(ctx).Where("aggregate_id = ?", "1").Update("delivered_at", old)
+	_ = o.conn(ctx).Where("aggregate_id = ?", "2").Update("delivered_at", recent)
+
+	n, err := o.Purge(ctx, time.Now().Add(-time.Hour))
+	if err != nil || n != 1 {
+		t.Errorf("Purge = %d, %v, want 1", n, err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	o, _ := newTestOutbox(t)
+	p := &recorder{}
+	r := newTestRelay(o, p)
+	r.PollInterval = time.Hour
+	r.BatchSize = 2
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	// more events than a batch are published at once, then Wake skips the wait for the poll
+	_ = o.Add(context.Background(), event("1", "a"), event("2", "b"), event("3", "c"))
+	r.Wake()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(p.events()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run error = %v, want %v", err, context.Canceled)
+	}
+	if got := p.events(); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("published %v, want [a b c]", got)
+	}
+}
@@ -0,0 +1,195 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/logger"
+	"github.com/go-monsters/monster/pkg/logger/native"
+	"github.com/go-monsters/monster/pkg/orm"
+	"gorm.io/gorm"
+)
+
+var (
+	DefaultPollInterval = 5 * time.Second
+	DefaultBatchSize    = 100
+	DefaultLease        = time.Minute // the time a relay has to publish an event before another one may
+	DefaultBackoff      = time.Second // wait before the first retry, doubled after every retry
+	DefaultMaxBackoff   = 5 * time.Minute
+)
+
+// Publisher publishes the events, e.g. to a message broker.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+type PublisherFunc func(ctx context.Context, event Event) error
+
+func (f PublisherFunc) Publish(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// Listener is implemented by the databases which notify the relay when events are added,
+// so that it doesn't wait for the next poll.
+type Listener interface {
+	Listen(ctx context.Context, channel string) (<-chan orm.Notification, error)
+}
+
+// Relay publishes the events of an outbox. Several relays may run on the same outbox:
+// an event is leased by the relay publishing it.
+//
+// Only the oldest undelivered event of an aggregate is published, so that a failing event
+// holds back the next ones of its aggregate until it is published.
+type Relay struct {
+	outbox    *Outbox
+	publisher Publisher
+	wake      chan struct{}
+
+	PollInterval time.Duration
+	BatchSize    int
+	Lease        time.Duration
+	Backoff      time.Duration
+	MaxBackoff   time.Duration
+	Logger       logger.Logger
+}
+
+func NewRelay(o *Outbox, publisher Publisher) *Relay {
+	return &Relay{
+		outbox:       o,
+		publisher:    publisher,
+		wake:         make(chan struct{}, 1),
+		PollInterval: DefaultPollInterval,
+		BatchSize:    DefaultBatchSize,
+		Lease:        DefaultLease,
+		Backoff:      DefaultBackoff,
+		MaxBackoff:   DefaultMaxBackoff,
+		Logger:       native.New(),
+	}
+}
+
+// Wake makes the relay look for events now instead of at the next poll.
+func (r *Relay) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes the events until ctx is done. If the database is a Listener, the relay
+// is woken up by the notifications of Outbox.Add and keeps polling in case one is missed.
+func (r *Relay) Run(ctx context.Context) error {
+	if listener, ok := r.outbox.db.(Listener); ok {
+		notifications, err := listener.Listen(ctx, r.outbox.Channel)
+		if err != nil {
+			r.Logger.Warn("outbox: could not listen to %s, polling only: %v", r.outbox.Channel, err)
+		} else {
+			go func() {
+				for range notifications {
+					r.Wake()
+				}
+			}()
+		}
+	}
+
+	for {
+		for {
+			n, err := r.RelayOnce(ctx)
+			if err != nil {
+				r.Logger.Error("outbox: %v", err)
+			}
+			if err != nil || n < r.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.wake:
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// RelayOnce publishes a batch of the events due, it returns the number of events found.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	events, err := r.due(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, event := range events {
+		if ctx.Err() != nil {
+			return len(events), ctx.Err()
+		}
+		if err := r.relay(ctx, event); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+// due finds the events to publish: the oldest undelivered event of every aggregate,
+// if it is not waiting for a retry or leased by a relay.
+func (r *Relay) due(ctx context.Context) ([]Event, error) {
+	table := r.outbox.Table
+	var events []Event
+	err := r.outbox.conn(ctx).
+		Where("delivered_at IS NULL AND next_attempt_at <= ?", time.Now()).
+		Where("NOT EXISTS (?)", r.outbox.db.GetConnection(ctx).Table(table+" AS previous").Select("1").
+			Where("previous.aggregate_type = "+table+".aggregate_type").
+			Where("previous.aggregate_id = "+table+".aggregate_id").
+			Where("previous.delivered_at IS NULL").
+			Where("previous.id < "+table+".id")).
+		Order("id").
+		Limit(r.BatchSize).
+		Find(&events).Error
+	return events, merror.Wrap(err, "could not find the events to publish")
+}
+
+// relay leases the event, publishes it then marks it delivered, or schedules its retry.
+// It returns an error only if the outbox can't be written.
+func (r *Relay) relay(ctx context.Context, event Event) error {
+	now := time.Now()
+	res := r.outbox.conn(ctx).
+		Where("id = ? AND delivered_at IS NULL AND next_attempt_at <= ?", event.ID, now).
+		Update("next_attempt_at", now.Add(r.Lease))
+	if res.Error != nil {
+		return merror.Wrapf(res.Error, "could not lease the event %d", event.ID)
+	}
+	if res.RowsAffected == 0 {
+		// leased by another relay
+		return nil
+	}
+
+	if err := r.publisher.Publish(ctx, event); err != nil {
+		backoff := r.backoff(event.Attempts)
+		r.Logger.Warn("outbox: could not publish the event %d, retrying in %s: %v", event.ID, backoff, err)
+		lastError := err.Error()
+		if len(lastError) > 1024 {
+			lastError = lastError[:1024]
+		}
+		return merror.Wrapf(r.outbox.conn(ctx).Where("id = ?", event.ID).Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": time.Now().Add(backoff),
+			"last_error":      lastError,
+		}).Error, "could not schedule the retry of the event %d", event.ID)
+	}
+
+	return merror.Wrapf(r.outbox.conn(ctx).Where("id = ?", event.ID).Updates(map[string]interface{}{
+		"attempts":     gorm.Expr("attempts + 1"),
+		"delivered_at": time.Now(),
+		"last_error":   "",
+	}).Error, "could not mark the event %d delivered", event.ID)
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	backoff := r.Backoff
+	for i := 0; i < attempts && backoff < r.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.MaxBackoff {
+		backoff = r.MaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,73 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"gorm.io/gorm/schema"
+)
+
+var ErrInvalidCursor = merror.Error("pagination: invalid cursor")
+
+// cursor points between two rows: the values are the ones of the ordering columns of the row
+// the page starts after, or ends before. An inclusive cursor points at the row itself, the page
+// starts or ends with it.
+type cursor struct {
+	Order     string            `json:"o"` // the ordering it was made for
+	Before    bool              `json:"b,omitempty"`
+	Inclusive bool              `json:"i,omitempty"`
+	Values    []json.RawMessage `json:"v"`
+}
+
+// encode signs the cursor, the token is base64(payload).base64(hmac).
+func (p *Paginator) encode(c cursor) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", merror.Wrap(err, "could not encode the cursor")
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(p.sign(payload)), nil
+}
+
+// decode checks the signature of token and decodes the values into the types of fields.
+func (p *Paginator) decode(token, order string, fields []*schema.Field) (*cursor, []interface{}, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, p.sign(payload)) {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	if c.Order != order || len(c.Values) != len(fields) {
+		return nil, nil, merror.Wrap(ErrInvalidCursor, "the cursor was made for another ordering")
+	}
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		value := reflect.New(field.FieldType)
+		if err := json.Unmarshal(c.Values[i], value.Interface()); err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		values[i] = value.Elem().Interface()
+	}
+	return &c, values, nil
+}
+
+func (p *Paginator) sign(payload []byte) []byte {
+	h := hmac.New(sha256.New, p.secret)
+	_, _ = h.Write(payload)
+	return h.Sum(nil)
+}
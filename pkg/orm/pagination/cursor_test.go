@@ -0,0 +1,58 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+func TestDecode(t *testing.T) {
+	p := New([]byte("secret"))
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	createdJSON, _ := json.Marshal(created)
+	valid, _ := p.encode(cursor{Order: "created_at,-id", Values: []json.RawMessage{createdJSON, json.RawMessage("42")}})
+	payload, signature, _ := strings.Cut(valid, ".")
+	forged, _ := New([]byte("other")).encode(cursor{Order: "created_at,-id", Values: []json.RawMessage{createdJSON, json.RawMessage("1")}})
+	tampered, _ := json.Marshal(cursor{Order: "created_at,-id", Values: []json.RawMessage{createdJSON, json.RawMessage("1")}})
+	wrongType, _ := p.encode(cursor{Order: "created_at,-id", Values: []json.RawMessage{json.RawMessage(`"x"`), json.RawMessage("1")}})
+
+	fields := []*schema.Field{{FieldType: reflect.TypeOf(time.Time{})}, {FieldType: reflect.TypeOf(0)}}
+	tests := []struct {
+		name    string
+		token   string
+		order   string
+		wantErr bool
+	}{
+		{name: "valid", token: valid, order: "created_at,-id"},
+		{name: "altered values", token: base64.RawURLEncoding.EncodeToString(tampered) + "." + signature, order: "created_at,-id", wantErr: true},
+		{name: "altered signature", token: payload + "." + signature[1:], order: "created_at,-id", wantErr: true},
+		{name: "signed with another secret", token: forged, order: "created_at,-id", wantErr: true},
+		{name: "no signature", token: payload, order: "created_at,-id", wantErr: true},
+		{name: "not base64", token: "!!!." + signature, order: "created_at,-id", wantErr: true},
+		{name: "another ordering", token: valid, order: "created_at,id", wantErr: true},
+		{name: "value of another type", token: wrongType, order: "created_at,-id", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, values, err := p.decode(tt.token, tt.order, fields)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decode error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalidCursor) {
+					t.Errorf("decode error = %v, want %v", err, ErrInvalidCursor)
+				}
+				return
+			}
+			if got, ok := values[0].(time.Time); !ok || !got.Equal(created) || values[1] != 42 {
+				t.Errorf("decode values = %v, want [%s 42]", values, created)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package pagination
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Column orders the pages, by its column or field name.
+type Column struct {
+	Name string
+	Desc bool
+}
+
+func Asc(name string) Column {
+	return Column{Name: name}
+}
+
+func Desc(name string) Column {
+	return Column{Name: name, Desc: true}
+}
+
+// Paginator pages by keyset: a page starts right after the ordering values of the last row
+// of the previous page, so its cost doesn't grow with the depth of the page.
+//
+// The ordering columns must not be null. The primary key is appended to them when missing,
+// which makes the ordering total and keeps the rows sharing the same values from being skipped.
+type Paginator struct {
+	secret  []byte
+	columns []Column
+}
+
+// New returns a Paginator ordering by columns, the cursors are signed with secret
+// so that a client can neither forge nor alter them.
+func New(secret []byte, columns ...Column) *Paginator {
+	return &Paginator{secret: secret, columns: columns}
+}
+
+// Keyset finds the page of the records of T matching db requested by req, db holds the conditions
+// of the query but not its ordering, limit or offset.
+func Keyset[T any](db *gorm.DB, p *Paginator, req Request) (*Page[T], error) {
+	columns, fields, err := p.resolve(db, new(T))
+	if err != nil {
+		return nil, err
+	}
+	order := orderOf(columns)
+
+	var c *cursor
+	tx := db.Model(new(T))
+	if req.Cursor != "" {
+		var values []interface{}
+		if c, values, err = p.decode(req.Cursor, order, fields); err != nil {
+			return nil, err
+		}
+		tx = tx.Where(seek(columns, values, c.Before, c.Inclusive))
+	}
+	backward := c != nil && c.Before
+	for _, column := range columns {
+		tx = tx.Order(clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: column.Name},
+			Desc:   column.Desc != backward,
+		})
+	}
+
+	limit := req.limit()
+	var items []T
+	if err := tx.Limit(limit + 1).Find(&items).Error; err != nil {
+		return nil, merror.Wrap(err, "could not find the page")
+	}
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page := &Page[T]{Items: items}
+	// An empty page past a cursor links back to the cursor, including its row.
+	var first, last []json.RawMessage
+	inclusive := len(items) == 0
+	if len(items) > 0 {
+		if first, err = valuesOf(db, fields, &items[0]); err != nil {
+			return nil, err
+		}
+		if last, err = valuesOf(db, fields, &items[len(items)-1]); err != nil {
+			return nil, err
+		}
+	} else if c != nil {
+		first, last = c.Values, c.Values
+	}
+	if (more && !backward) || (backward && last != nil) {
+		if page.Next, err = p.request(cursor{Order: order, Inclusive: inclusive, Values: last}, req.Limit); err != nil {
+			return nil, err
+		}
+	}
+	if (more && backward) || (c != nil && !backward && first != nil) {
+		if page.Prev, err = p.request(cursor{Order: order, Before: true, Inclusive: inclusive, Values: first}, req.Limit); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func (p *Paginator) request(c cursor, limit int) (*Request, error) {
+	token, err := p.encode(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Request{Cursor: token, Limit: limit}, nil
+}
+
+// resolve finds the fields of the ordering columns in the schema of model, appending the primary key.
+func (p *Paginator) resolve(db *gorm.DB, model interface{}) ([]Column, []*schema.Field, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, nil, merror.Wrap(err, "could not parse the model")
+	}
+
+	columns := make([]Column, 0, len(p.columns)+1)
+	fields := make([]*schema.Field, 0, len(p.columns)+1)
+	hasPrimaryKey := false
+	for _, column := range p.columns {
+		field := stmt.Schema.LookUpField(column.Name)
+		if field == nil || field.DBName == "" {
+			return nil, nil, merror.Errorf("the model %s has no column %s", stmt.Schema.Name, column.Name)
+		}
+		hasPrimaryKey = hasPrimaryKey || field == stmt.Schema.PrioritizedPrimaryField
+		columns = append(columns, Column{Name: field.DBName, Desc: column.Desc})
+		fields = append(fields, field)
+	}
+	if !hasPrimaryKey {
+		field := stmt.Schema.PrioritizedPrimaryField
+		if field == nil {
+			return nil, nil, merror.Errorf("the model %s has no primary key to break the ties", stmt.Schema.Name)
+		}
+		desc := len(columns) > 0 && columns[len(columns)-1].Desc
+		columns = append(columns, Column{Name: field.DBName, Desc: desc})
+		fields = append(fields, field)
+	}
+	return columns, fields, nil
+}
+
+// seek selects the rows after values in the ordering, or before them if before is true:
+// (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ..., each comparison reversed on a descending column.
+// It is expanded instead of a row comparison, which cannot mix the directions. The last comparison
+// includes the row of values if inclusive is true.
+func seek(columns []Column, values []interface{}, before, inclusive bool) clause.Expression {
+	exprs := make([]clause.Expression, 0, len(columns))
+	for i, column := range columns {
+		and := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			and = append(and, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: columns[j].Name}, Value: values[j]})
+		}
+		col := clause.Column{Table: clause.CurrentTable, Name: column.Name}
+		orEqual := inclusive && i == len(columns)-1
+		switch {
+		case column.Desc != before && orEqual:
+			and = append(and, clause.Lte{Column: col, Value: values[i]})
+		case column.Desc != before:
+			and = append(and, clause.Lt{Column: col, Value: values[i]})
+		case orEqual:
+			and = append(and, clause.Gte{Column: col, Value: values[i]})
+		default:
+			and = append(and, clause.Gt{Column: col, Value: values[i]})
+		}
+		exprs = append(exprs, clause.And(and...))
+	}
+	if len(exprs) == 1 {
+		// gorm joins an Or of a single expression to the other conditions with OR
+		return exprs[0]
+	}
+	return clause.Or(exprs...)
+}
+
+func valuesOf(db *gorm.DB, fields []*schema.Field, item interface{}) ([]json.RawMessage, error) {
+	rv := reflect.ValueOf(item).Elem()
+	values := make([]json.RawMessage, len(fields))
+	for i, field := range fields {
+		value, _ := field.ValueOf(db.Statement.Context, rv)
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, merror.Wrapf(err, "could not encode the value of %s in the cursor", field.Name)
+		}
+		values[i] = data
+	}
+	return values, nil
+}
+
+// orderOf identifies the ordering in the cursors, e.g. "created_at,-id".
+func orderOf(columns []Column) string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		if column.Desc {
+			names[i] = "-" + column.Name
+		} else {
+			names[i] = column.Name
+		}
+	}
+	return strings.Join(names, ",")
+}
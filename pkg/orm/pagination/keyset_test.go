@@ -0,0 +1,221 @@
+package pagination
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/orm/sqlite"
+	"gorm.io/gorm"
+)
+
+type item struct {
+	ID        int `gorm:"primaryKey"`
+	Score     int
+	CreatedAt time.Time
+}
+
+// newItems stores 10 items, the scores have ties and the creation times decrease with the ids.
+func newItems(t *testing.T) *gorm.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := sqlite.Open(ctx, sqlite.Config{InMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	conn := db.GetConnection(ctx)
+	if err := conn.AutoMigrate(&item{}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for id := 1; id <= 10; id++ {
+		it := item{ID: id, Score: id % 3, CreatedAt: start.Add(-time.Duration(id) * time.Hour)}
+		if err := conn.Create(&it).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return conn
+}
+
+func ids(items []item) []int {
+	ids := []int{}
+	for _, it := range items {
+		ids = append(ids, it.ID)
+	}
+	return ids
+}
+
+func TestKeyset(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []Column
+		where   func(db *gorm.DB) *gorm.DB
+		want    []int
+	}{
+		{name: "primary key", want: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{name: "descending", columns: []Column{Desc("id")}, want: []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}},
+		{name: "ties broken by the primary key", columns: []Column{Asc("score")}, want: []int{3, 6, 9, 1, 4, 7, 10, 2, 5, 8}},
+		{name: "descending ties", columns: []Column{Desc("Score")}, want: []int{8, 5, 2, 10, 7, 4, 1, 9, 6, 3}},
+		{name: "mixed directions", columns: []Column{Desc("score"), Asc("created_at")}, want: []int{8, 5, 2, 10, 7, 4, 1, 9, 6, 3}},
+		{name: "time column", columns: []Column{Asc("created_at")}, want: []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}},
+		{
+			name:  "conditions",
+			where: func(db *gorm.DB) *gorm.DB { return db.Where("score <> ?", 0) },
+			want:  []int{1, 2, 4, 5, 7, 8, 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newItems(t)
+			if tt.where != nil {
+				db = tt.where(db)
+			}
+			p := New([]byte("secret"), tt.columns...)
+			query := func(req Request) *Page[item] {
+				t.Helper()
+				page, err := Keyset[item](db.Session(&gorm.Session{}), p, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page.Items) > 3 {
+					t.Fatalf("page of %d items, want 3 at most", len(page.Items))
+				}
+				return page
+			}
+
+			// walk forward to the last page, then back to the first one
+			var forward [][]int
+			page := query(Request{Limit: 3})
+			if page.Prev != nil {
+				t.Error("the first page has a prev link")
+			}
+			var all []int
+			for {
+				forward = append(forward, ids(page.Items))
+				all = append(all, ids(page.Items)...)
+				if page.Next == nil {
+					break
+				}
+				page = query(*page.Next)
+			}
+			if !reflect.DeepEqual(all, tt.want) {
+				t.Fatalf("pages forward = %v, want %v", forward, tt.want)
+			}
+
+			for i := len(forward) - 2; i >= 0; i-- {
+				if page.Prev == nil {
+					t.Fatalf("page %d has no prev link", i+1)
+				}
+				page = query(*page.Prev)
+				if !reflect.DeepEqual(ids(page.Items), forward[i]) {
+					t.Errorf("page %d backward = %v, want %v", i, ids(page.Items), forward[i])
+				}
+			}
+			if page.Prev != nil {
+				t.Error("the first page reached backward has a prev link")
+			}
+		})
+	}
+}
+
+func TestKeysetEmptyPage(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor cursor
+		back   func(page *Page[item]) *Request
+	}{
+		{
+			name:   "after the last row",
+			cursor: cursor{Order: "id", Values: []json.RawMessage{json.RawMessage("10")}},
+			back:   func(page *Page[item]) *Request { return page.Prev },
+		},
+		{
+			name:   "before the first row",
+			cursor: cursor{Order: "id", Before: true, Values: []json.RawMessage{json.RawMessage("1")}},
+			back:   func(page *Page[item]) *Request { return page.Next },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the rows past the cursor were deleted since it was made
+			db := newItems(t)
+			p := New([]byte("secret"))
+			req, _ := p.request(tt.cursor, 10)
+			empty, err := Keyset[item](db.Session(&gorm.Session{}), p, *req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(empty.Items) != 0 || tt.back(empty) == nil {
+				t.Fatalf("Keyset past the rows = %+v, want an empty page linking back", empty)
+			}
+
+			// the link back includes the row of the cursor
+			page, err := Keyset[item](db.Session(&gorm.Session{}), p, *tt.back(empty))
+			if err != nil || !reflect.DeepEqual(ids(page.Items), []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}) {
+				t.Errorf("page linked back = %v, %v, want the 10 items", ids(page.Items), err)
+			}
+		})
+	}
+}
+
+func TestKeysetErrors(t *testing.T) {
+	db := newItems(t)
+	other, _ := New([]byte("other")).encode(cursor{Order: "id", Values: []json.RawMessage{json.RawMessage("3")}})
+	tests := []struct {
+		name    string
+		p       *Paginator
+		req     Request
+		wantErr error
+	}{
+		{name: "unknown column", p: New([]byte("secret"), Asc("rank"))},
+		{name: "forged cursor", p: New([]byte("secret")), req: Request{Cursor: other}, wantErr: ErrInvalidCursor},
+		{name: "cursor of another ordering", p: New([]byte("other"), Desc("id")), req: Request{Cursor: other}, wantErr: ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Keyset[item](db.Session(&gorm.Session{}), tt.p, tt.req)
+			if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Keyset error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOffset(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      Request
+		want     []int
+		wantNext *Request
+		wantPrev *Request
+	}{
+		{name: "first page", req: Request{Limit: 4}, want: []int{1, 2, 3, 4}, wantNext: &Request{Page: 2, Limit: 4}},
+		{name: "middle page", req: Request{Page: 2, Limit: 4}, want: []int{5, 6, 7, 8}, wantNext: &Request{Page: 3, Limit: 4}, wantPrev: &Request{Page: 1, Limit: 4}},
+		{name: "last page", req: Request{Page: 3, Limit: 4}, want: []int{9, 10}, wantPrev: &Request{Page: 2, Limit: 4}},
+		{name: "exact last page", req: Request{Page: 2, Limit: 5}, want: []int{6, 7, 8, 9, 10}, wantPrev: &Request{Page: 1, Limit: 5}},
+		{name: "past the end", req: Request{Page: 5, Limit: 4}, want: []int{}, wantPrev: &Request{Page: 4, Limit: 4}},
+		{name: "default limit", req: Request{}, want: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newItems(t)
+			page, err := Offset[item](db.Order("id"), tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ids(page.Items), tt.want) {
+				t.Errorf("Offset = %v, want %v", ids(page.Items), tt.want)
+			}
+			if page.Total == nil || *page.Total != 10 {
+				t.Errorf("Total = %v, want 10", page.Total)
+			}
+			if !reflect.DeepEqual(page.Next, tt.wantNext) || !reflect.DeepEqual(page.Prev, tt.wantPrev) {
+				t.Errorf("Next, Prev = %+v, %+v, want %+v, %+v", page.Next, page.Prev, tt.wantNext, tt.wantPrev)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package pagination
+
+import (
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"gorm.io/gorm"
+)
+
+// Offset finds the page of the records of T matching db requested by req.Page, along with the total
+// count of the records. db holds the conditions and the ordering of the query but not its limit or offset.
+// It suits the small tables and the UIs jumping to a page, Keyset suits the large tables.
+func Offset[T any](db *gorm.DB, req Request) (*Page[T], error) {
+	var total int64
+	if err := db.Session(&gorm.Session{}).Model(new(T)).Count(&total).Error; err != nil {
+		return nil, merror.Wrap(err, "could not count the records")
+	}
+
+	number := req.Page
+	if number < 1 {
+		number = 1
+	}
+	limit := req.limit()
+	var items []T
+	if err := db.Model(new(T)).Offset((number - 1) * limit).Limit(limit).Find(&items).Error; err != nil {
+		return nil, merror.Wrap(err, "could not find the page")
+	}
+
+	page := &Page[T]{Items: items, Total: &total}
+	if int64(number*limit) < total {
+		page.Next = &Request{Page: number + 1, Limit: req.Limit}
+	}
+	if number > 1 {
+		page.Prev = &Request{Page: number - 1, Limit: req.Limit}
+	}
+	return page, nil
+}
@@ -0,0 +1,138 @@
+// Package pagination pages the results of the gorm queries, either by keyset with signed cursors
+// or by offset with a total count, and builds the next and prev links of the HTTP responses.
+package pagination
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+)
+
+// The query parameters read by ParseQuery and written in the links.
+const (
+	CursorParam = "cursor"
+	PageParam   = "page"
+	LimitParam  = "limit"
+)
+
+var (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var ErrInvalidRequest = merror.Error("pagination: invalid request")
+
+// Request asks for a page, by Cursor in keyset mode or by Page in offset mode.
+type Request struct {
+	Cursor string // empty for the first page
+	Page   int    // starts at 1, 0 means the first page
+	Limit  int    // 0 means DefaultLimit, capped to MaxLimit
+}
+
+// ParseQuery reads a Request from the cursor, page and limit query parameters.
+func ParseQuery(query url.Values) (Request, error) {
+	req := Request{Cursor: query.Get(CursorParam)}
+	var err error
+	if s := query.Get(PageParam); s != "" {
+		if req.Page, err = strconv.Atoi(s); err != nil || req.Page < 1 {
+			return Request{}, merror.Wrapf(ErrInvalidRequest, "the page must be a positive number, got %q", s)
+		}
+	}
+	if s := query.Get(LimitParam); s != "" {
+		if req.Limit, err = strconv.Atoi(s); err != nil || req.Limit < 1 {
+			return Request{}, merror.Wrapf(ErrInvalidRequest, "the limit must be a positive number, got %q", s)
+		}
+	}
+	return req, nil
+}
+
+// Values returns the query parameters of the request.
+func (r Request) Values() url.Values {
+	values := url.Values{}
+	if r.Cursor != "" {
+		values.Set(CursorParam, r.Cursor)
+	}
+	if r.Page > 0 {
+		values.Set(PageParam, strconv.Itoa(r.Page))
+	}
+	if r.Limit > 0 {
+		values.Set(LimitParam, strconv.Itoa(r.Limit))
+	}
+	return values
+}
+
+func (r Request) limit() int {
+	switch {
+	case r.Limit <= 0:
+		return DefaultLimit
+	case r.Limit > MaxLimit:
+		return MaxLimit
+	default:
+		return r.Limit
+	}
+}
+
+// Page holds the items of a page and the requests of its neighbours.
+type Page[T any] struct {
+	Items []T
+	Next  *Request // nil on the last page
+	Prev  *Request // nil on the first page
+	Total *int64   // counted in offset mode only
+}
+
+// Links are the URLs of the neighbours of a page.
+type Links struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// Links builds the URLs of the neighbours of the page from base, the URL of the current page.
+func (p *Page[T]) Links(base *url.URL) Links {
+	return Links{Next: link(base, p.Next), Prev: link(base, p.Prev)}
+}
+
+func link(base *url.URL, req *Request) string {
+	if req == nil {
+		return ""
+	}
+	query := base.Query()
+	query.Del(CursorParam)
+	query.Del(PageParam)
+	query.Del(LimitParam)
+	for key, values := range req.Values() {
+		query[key] = values
+	}
+	u := *base
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Header formats the links as the value of a Link header (RFC 8288).
+func (l Links) Header() string {
+	var links []string
+	if l.Next != "" {
+		links = append(links, "<"+l.Next+`>; rel="next"`)
+	}
+	if l.Prev != "" {
+		links = append(links, "<"+l.Prev+`>; rel="prev"`)
+	}
+	return strings.Join(links, ", ")
+}
+
+// Response is the standard body of a paginated response.
+type Response[T any] struct {
+	Data  []T    `json:"data"`
+	Links Links  `json:"links"`
+	Total *int64 `json:"total,omitempty"`
+}
+
+// Response builds the body answering the page, base is the URL of the current page.
+func (p *Page[T]) Response(base *url.URL) Response[T] {
+	data := p.Items
+	if data == nil {
+		data = []T{}
+	}
+	return Response[T]{Data: data, Links: p.Links(base), Total: p.Total}
+}
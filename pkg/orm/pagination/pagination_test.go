@@ -0,0 +1,121 @@
+package pagination
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    Request
+		wantErr bool
+	}{
+		{query: "", want: Request{}},
+		{query: "cursor=abc.def&limit=10", want: Request{Cursor: "abc.def", Limit: 10}},
+		{query: "page=3&limit=5&sort=name", want: Request{Page: 3, Limit: 5}},
+		{query: "page=0", wantErr: true},
+		{query: "page=x", wantErr: true},
+		{query: "limit=-1", wantErr: true},
+		{query: "limit=ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			got, err := ParseQuery(query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseQuery error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("ParseQuery error = %v, want %v", err, ErrInvalidRequest)
+			}
+			if got != tt.want {
+				t.Errorf("ParseQuery = %+v, want %+v", got, tt.want)
+			}
+			// the request round-trips through its query
+			if err == nil {
+				if back, _ := ParseQuery(got.Values()); back != got {
+					t.Errorf("ParseQuery(Values()) = %+v, want %+v", back, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestLimit(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{limit: 0, want: DefaultLimit},
+		{limit: -5, want: DefaultLimit},
+		{limit: 7, want: 7},
+		{limit: MaxLimit, want: MaxLimit},
+		{limit: MaxLimit + 1, want: MaxLimit},
+	}
+	for _, tt := range tests {
+		if got := (Request{Limit: tt.limit}).limit(); got != tt.want {
+			t.Errorf("limit of %d = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	base, _ := url.Parse("https://api.local/users?sort=name&cursor=old&page=2&limit=9")
+	tests := []struct {
+		name       string
+		page       Page[int]
+		want       Links
+		wantHeader string
+	}{
+		{name: "single page", page: Page[int]{}, want: Links{}},
+		{
+			name:       "next cursor",
+			page:       Page[int]{Next: &Request{Cursor: "abc", Limit: 5}},
+			want:       Links{Next: "https://api.local/users?cursor=abc&limit=5&sort=name"},
+			wantHeader: `<https://api.local/users?cursor=abc&limit=5&sort=name>; rel="next"`,
+		},
+		{
+			name: "next and prev pages",
+			page: Page[int]{Next: &Request{Page: 3}, Prev: &Request{Page: 1}},
+			want: Links{
+				Next: "https://api.local/users?page=3&sort=name",
+				Prev: "https://api.local/users?page=1&sort=name",
+			},
+			wantHeader: `<https://api.local/users?page=3&sort=name>; rel="next", <https://api.local/users?page=1&sort=name>; rel="prev"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.page.Links(base)
+			if got != tt.want {
+				t.Errorf("Links = %+v, want %+v", got, tt.want)
+			}
+			if header := got.Header(); header != tt.wantHeader {
+				t.Errorf("Header = %s, want %s", header, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestResponse(t *testing.T) {
+	base, _ := url.Parse("/users")
+	total := int64(2)
+	tests := []struct {
+		name string
+		page Page[int]
+		want Response[int]
+	}{
+		{name: "empty", page: Page[int]{}, want: Response[int]{Data: []int{}}},
+		{name: "items", page: Page[int]{Items: []int{1, 2}, Total: &total}, want: Response[int]{Data: []int{1, 2}, Total: &total}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.page.Response(base); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Response = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package postgresql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/orm"
+	"github.com/jackc/pgx/v4"
+)
+
+// Notification is a message received on a channel, from NOTIFY or pg_notify.
+type Notification = orm.Notification
+
+var (
+	// DefaultListenBuffer is the capacity of the channels returned by Listen.
+	DefaultListenBuffer = 64
+
+	maxListenBackoff = 30 * time.Second
+)
+
+// Listen subscribes to channel until ctx is done, the returned channel is then closed.
+//
+// The notifications of every subscription are received on a connection dedicated to them,
+// which reconnects and subscribes again when it is lost. Since the notifications sent meanwhile
+// are lost, every subscriber then receives a Notification with an empty payload to resynchronize.
+// A notification is dropped for a subscriber whose channel is full.
+func (m *Postgresql) Listen(ctx context.Context, channel string) (<-chan Notification, error) {
+	if channel == "" {
+		return nil, merror.Error("could not listen: the channel is empty")
+	}
+	return m.listener.subscribe(ctx, channel)
+}
+
+// Notify sends payload on channel, in the transaction of ctx if any, in which case it is
+// delivered when the transaction commits.
+func (m *Postgresql) Notify(ctx context.Context, channel, payload string) error {
+	err := m.GetConnection(ctx).Exec("SELECT pg_notify(?, ?)", channel, payload).Error
+	return merror.Wrapf(err, "could not notify %s", channel)
+}
+
+type subscription struct {
+	channel string
+	ch      chan Notification
+}
+
+// listener multiplexes the subscriptions on a single connection, it runs while there are subscriptions.
+type listener struct {
+	address string
+	backoff time.Duration
+
+	mu        sync.Mutex
+	subs      map[string]map[*subscription]struct{}
+	running   bool
+	interrupt context.CancelFunc // stops waiting for a notification, to update the subscriptions
+	dirty     bool               // the channels changed since the connection last listened
+}
+
+func newListener(address string, backoff time.Duration) *listener {
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	return &listener{
+		address: address,
+		backoff: backoff,
+		subs:    make(map[string]map[*subscription]struct{}),
+	}
+}
+
+func (l *listener) subscribe(ctx context.Context, channel string) (<-chan Notification, error) {
+	sub := &subscription{channel: channel, ch: make(chan Notification, DefaultListenBuffer)}
+
+	l.mu.Lock()
+	if l.subs[channel] == nil {
+		l.subs[channel] = make(map[*subscription]struct{})
+	}
+	l.subs[channel][sub] = struct{}{}
+	l.dirty = true
+	if !l.running {
+		l.running = true
+		go l.run()
+	} else if l.interrupt != nil {
+		l.interrupt()
+	}
+	l.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		l.unsubscribe(sub)
+	}()
+	return sub.ch, nil
+}
+
+func (l *listener) unsubscribe(sub *subscription) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	subs, ok := l.subs[sub.channel]
+	if _, subscribed := subs[sub]; !ok || !subscribed {
+		return
+	}
+	delete(subs, sub)
+	close(sub.ch)
+	if len(subs) == 0 {
+		delete(l.subs, sub.channel)
+		l.dirty = true
+		if l.interrupt != nil {
+			l.interrupt()
+		}
+	}
+}
+
+// close ends every subscription, which closes the connection.
+func (l *listener) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for channel, subs := range l.subs {
+		for sub := range subs {
+			close(sub.ch)
+		}
+		delete(l.subs, channel)
+	}
+	if l.interrupt != nil {
+		l.interrupt()
+	}
+}
+
+// run connects, with backoff, and receives the notifications until there are no more subscriptions.
+func (l *listener) run() {
+	backoff := l.backoff
+	reconnected := false
+	for {
+		if l.stopIfIdle() {
+			return
+		}
+		conn, err := pgx.Connect(context.Background(), l.address)
+		if err != nil {
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > maxListenBackoff {
+				backoff = maxListenBackoff
+			}
+			continue
+		}
+		backoff = l.backoff
+
+		err = l.receive(conn, reconnected)
+		_ = conn.Close(context.Background())
+		if err == nil {
+			return
+		}
+		reconnected = true
+	}
+}
+
+// receive listens to the channels subscribed and dispatches the notifications.
+// It returns nil when there are no more subscriptions, or the error which broke the connection.
+func (l *listener) receive(conn *pgx.Conn, reconnected bool) error {
+	listened := make(map[string]bool)
+	for {
+		if l.stopIfIdle() {
+			return nil
+		}
+		channels := l.channels()
+		for channel := range channels {
+			if !listened[channel] {
+				if _, err := conn.Exec(context.Background(), "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+					return err
+				}
+				listened[channel] = true
+			}
+		}
+		for channel := range listened {
+			if !channels[channel] {
+				if _, err := conn.Exec(context.Background(), "UNLISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+					return err
+				}
+				delete(listened, channel)
+			}
+		}
+		if reconnected {
+			for channel := range listened {
+				l.dispatch(Notification{Channel: channel})
+			}
+			reconnected = false
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		l.mu.Lock()
+		l.interrupt = cancel
+		if l.dirty {
+			cancel()
+		}
+		l.mu.Unlock()
+		n, err := conn.WaitForNotification(ctx)
+		interrupted := ctx.Err() != nil
+		cancel()
+		l.mu.Lock()
+		l.interrupt = nil
+		l.mu.Unlock()
+
+		if n != nil {
+			l.dispatch(Notification{Channel: n.Channel, Payload: n.Payload})
+		}
+		if err != nil && !interrupted {
+			return err
+		}
+	}
+}
+
+func (l *listener) channels() map[string]bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.dirty = false
+	channels := make(map[string]bool, len(l.subs))
+	for channel := range l.subs {
+		channels[channel] = true
+	}
+	return channels
+}
+
+func (l *listener) dispatch(n Notification) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for sub := range l.subs[n.Channel] {
+		select {
+		case sub.ch <- n:
+		default:
+		}
+	}
+}
+
+// stopIfIdle marks the listener stopped if there are no subscriptions, a new one starts it again.
+func (l *listener) stopIfIdle() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.subs) == 0 {
+		l.running = false
+		return true
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+
+	"github.com/go-monsters/monster/pkg/orm"
+)
+
+// Lock takes the advisory lock name with pg_advisory_lock, waiting for it until ctx is done.
+func (m *Postgresql) Lock(ctx context.Context, name string) (*orm.Lock, error) {
+	return m.lock(ctx, name, func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey(name))
+		return err == nil, err
+	})
+}
+
+// TryLock takes the advisory lock name with pg_try_advisory_lock if it is free, otherwise it returns orm.ErrLockTaken.
+func (m *Postgresql) TryLock(ctx context.Context, name string) (*orm.Lock, error) {
+	return m.lock(ctx, name, func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		var taken bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockKey(name)).Scan(&taken)
+		return taken, err
+	})
+}
+
+func (m *Postgresql) lock(ctx context.Context, name string, acquire orm.LockFunc) (*orm.Lock, error) {
+	db, err := m.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return orm.AcquireLock(ctx, db, name, acquire, func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		var released bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey(name)).Scan(&released)
+		return released, err
+	})
+}
+
+// lockKey hashes name into the bigint key of the advisory locks.
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,22 @@
+package postgresql
+
+import "testing"
+
+func TestLockKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{name: "same name", a: "jobs", b: "jobs", equal: true},
+		{name: "other name", a: "jobs", b: "mails"},
+		{name: "empty name", a: "", b: "jobs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lockKey(tt.a) == lockKey(tt.b); got != tt.equal {
+				t.Errorf("lockKey(%q) == lockKey(%q) is %v, want %v", tt.a, tt.b, got, tt.equal)
+			}
+		})
+	}
+}
@@ -2,44 +2,87 @@ package postgresql
 
 import (
 	"context"
-	"sync"
-	"time"
+	"net"
+	"net/url"
+	"strconv"
 
 	"github.com/go-monsters/monster/pkg/orm"
+	"github.com/go-monsters/monster/pkg/orm/audit"
 	postgres "go.elastic.co/apm/module/apmgormv2/v2/driver/postgres"
-	"gorm.io/gorm"
 )
 
+const defaultPort = 5432
+
 type Postgresql struct {
-	address    string
-	dbConnOnce sync.Once
-	db         *gorm.DB
+	*orm.Conn
+
+	listener *listener
+}
+
+// New returns a database connecting on the first call of GetConnection with the default options.
+// If it can't connect, the returned *gorm.DB carries the error and the next call tries again.
+func New(address string, opts ...orm.Option) orm.Database {
+	return newPostgresql(address, opts)
 }
 
-func New(address string) orm.Database {
+// Open connects to the database, retrying as configured by the options, and returns the error
+// if the connection can't be made.
+func Open(ctx context.Context, address string, opts ...orm.Option) (*Postgresql, error) {
+	m := newPostgresql(address, opts)
+	if _, err := m.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func newPostgresql(address string, opts []orm.Option) *Postgresql {
+	o := newOptions(opts)
 	return &Postgresql{
-		address: address,
+		Conn:     orm.NewConn("postgresql", postgres.Open, address, o),
+		listener: newListener(address, o.RetryBackoff),
 	}
 }
 
-func (m *Postgresql) GetConnection(ctx context.Context) *gorm.DB {
-	if m.db == nil {
-		m.dbConnOnce.Do(func() {
-			var err error
-			m.db, err = gorm.Open(postgres.Open(m.address), &gorm.Config{})
-			if err != nil {
-				panic(err)
-			}
-
-			db, err := m.db.DB()
-			if err != nil {
-				panic(err)
-			}
-			db.SetMaxIdleConns(10)
-			db.SetMaxOpenConns(20)
-			db.SetConnMaxLifetime(time.Hour)
-		})
+// newOptions uses the audit plugin by default.
+func newOptions(opts []orm.Option) orm.Options {
+	o := orm.NewOptions(opts...)
+	audit.Register(&o)
+	return o
+}
+
+// Close closes the connection pool and ends the subscriptions of Listen, the next call of GetConnection connects again.
+func (m *Postgresql) Close() error {
+	m.listener.close()
+	return m.Conn.Close()
+}
+
+// DSN builds the address of the database from the config, as a postgres:// URL.
+func DSN(config orm.Config) string {
+	if config.DSN != "" {
+		return config.DSN
+	}
+	port := config.Port
+	if port == 0 {
+		port = defaultPort
 	}
+	params := url.Values{}
+	for key, val := range config.Params {
+		params.Set(key, val)
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		Host:     net.JoinHostPort(config.Host, strconv.Itoa(port)),
+		Path:     "/" + config.Database,
+		RawQuery: params.Encode(),
+	}
+	if config.User != "" {
+		u.User = url.UserPassword(config.User, config.Password)
+	}
+	return u.String()
+}
 
-	return m.db.WithContext(ctx)
+func init() {
+	orm.RegisterDatabaseImpl("postgresql", func(config orm.Config) (orm.Database, error) {
+		return New(DSN(config), config.Options()...), nil
+	})
 }
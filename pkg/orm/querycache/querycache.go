@@ -0,0 +1,278 @@
+// Package querycache caches the results of the gorm queries in a cache.Cache.
+//
+// Caching is opt-in per query with the Cached scope:
+//
+//	db.Scopes(querycache.Cached(time.Minute)).Where("active = ?", true).Find(&users)
+//
+// A cached result is keyed by the SQL, its bind arguments and a generation of every table the query reads.
+// A write through a connection using the plugin renews the generations of the tables it touches,
+// so that the results cached before are never read again (see Plugin for the transactions).
+// The generations live in the cache too, so the processes sharing a cache invalidate each other.
+package querycache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/cache"
+	"github.com/go-monsters/monster/pkg/orm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+const (
+	pluginName = "monster:querycache"
+	ttlKey     = "monster:querycache:ttl"
+)
+
+// DefaultPrefix prefixes the keys written by the plugin.
+var DefaultPrefix = "querycache:"
+
+// tablePattern finds the tables named in a statement, with or without quotes and schema.
+var tablePattern = regexp.MustCompile("(?i)\\b(?:from|join|into|update)\\s+([\\w.`\"]+)")
+
+// Cached opts a query in the cache, its result is kept for ttl at most.
+func Cached(ttl time.Duration) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(ttlKey, ttl)
+	}
+}
+
+// Plugin is a gorm plugin caching the results of the queries using the Cached scope.
+// Queries run in a transaction are never cached, they may read rows not committed yet.
+//
+// A write made in a transaction invalidates the tables when it is executed and again when the
+// transaction commits, if it was started by orm.WithTx, so that a result read by another connection
+// before the commit is not kept. The writes of other transactions are only invalidated when executed.
+type Plugin struct {
+	cache  cache.Cache
+	Prefix string
+}
+
+func New(c cache.Cache) *Plugin {
+	return &Plugin{cache: c, Prefix: DefaultPrefix}
+}
+
+func (p *Plugin) Name() string {
+	return pluginName
+}
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Query().Replace("gorm:query", p.query); err != nil {
+		return err
+	}
+	if err := db.Callback().Create().After("gorm:commit_or_rollback_transaction").Register(pluginName+":create", p.invalidate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:commit_or_rollback_transaction").Register(pluginName+":update", p.invalidate); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().After("gorm:commit_or_rollback_transaction").Register(pluginName+":delete", p.invalidate); err != nil {
+		return err
+	}
+	return db.Callback().Raw().After("gorm:raw").Register(pluginName+":raw", p.invalidate)
+}
+
+// Invalidate renews the generations of tables, for the writes not made through the plugin.
+func (p *Plugin) Invalidate(ctx context.Context, tables ...string) error {
+	for _, table := range tables {
+		if err := p.cache.Put(ctx, p.generationKey(table), newGeneration(), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query replaces the gorm query callback, serving the result from the cache when it can.
+func (p *Plugin) query(db *gorm.DB) {
+	ttl, ok := db.Get(ttlKey)
+	if !ok || db.Error != nil || db.DryRun || inTransaction(db) {
+		callbacks.Query(db)
+		return
+	}
+
+	callbacks.BuildQuerySQL(db)
+	if db.Error != nil {
+		return
+	}
+	ctx := db.Statement.Context
+	key, err := p.key(ctx, db)
+	if err != nil {
+		callbacks.Query(db)
+		return
+	}
+	if p.load(ctx, db, key) {
+		return
+	}
+
+	callbacks.Query(db)
+	if db.Error == nil {
+		p.store(ctx, db, key, ttl.(time.Duration))
+	}
+}
+
+// invalidate renews the generations of the tables written by a successful statement once its own
+// transaction is committed, and again after the commit for a statement of an explicit transaction.
+func (p *Plugin) invalidate(db *gorm.DB) {
+	if db.Error != nil || db.DryRun {
+		return
+	}
+	ctx, written := db.Statement.Context, tables(db.Statement)
+	_ = p.Invalidate(ctx, written...)
+	if inTransaction(db) {
+		orm.AfterCommit(db, func() {
+			_ = p.Invalidate(ctx, written...)
+		})
+	}
+}
+
+// key hashes the SQL, the arguments, the type of the destination and the generations of the tables.
+func (p *Plugin) key(ctx context.Context, db *gorm.DB) (string, error) {
+	generations, err := p.generations(ctx, tables(db.Statement))
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\x00%s\x00%T\x00", db.Dialector.Name(), db.Statement.SQL.String(), db.Statement.Dest)
+	for _, v := range db.Statement.Vars {
+		_, _ = fmt.Fprintf(h, "%T:%v\x00", v, v)
+	}
+	for _, generation := range generations {
+		_, _ = fmt.Fprintf(h, "%s\x00", generation)
+	}
+	return p.Prefix + "q:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// generations reads the generations of the tables, starting a new one for the tables without.
+func (p *Plugin) generations(ctx context.Context, tables []string) ([]string, error) {
+	keys := make([]string, len(tables))
+	for i, table := range tables {
+		keys[i] = p.generationKey(table)
+	}
+	values, _ := p.cache.GetMulti(ctx, keys)
+
+	generations := make([]string, len(tables))
+	for i := range tables {
+		var value interface{}
+		if i < len(values) {
+			value = values[i]
+		}
+		switch v := value.(type) {
+		case string:
+			generations[i] = v
+		case []byte:
+			generations[i] = string(v)
+		default:
+			generations[i] = newGeneration()
+			if err := p.cache.Put(ctx, keys[i], generations[i], 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return generations, nil
+}
+
+func (p *Plugin) generationKey(table string) string {
+	return p.Prefix + "t:" + table
+}
+
+// load decodes a cached result into the destination of the statement, it reports whether there was one.
+func (p *Plugin) load(ctx context.Context, db *gorm.DB, key string) bool {
+	value, err := p.cache.Get(ctx, key)
+	if err != nil {
+		return false
+	}
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return false
+	}
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	var rows int64
+	if err := dec.Decode(&rows); err != nil {
+		return false
+	}
+	if rows > 0 {
+		if err := dec.Decode(db.Statement.Dest); err != nil {
+			return false
+		}
+	} else if rv := db.Statement.ReflectValue; rv.Kind() == reflect.Slice && rv.CanSet() {
+		rv.Set(reflect.MakeSlice(rv.Type(), 0, 0))
+	}
+
+	db.RowsAffected = rows
+	if rows == 0 && db.Statement.RaiseErrorOnNotFound {
+		_ = db.AddError(gorm.ErrRecordNotFound)
+	}
+	return true
+}
+
+// store caches the result of the statement, a result which cannot be encoded is not cached.
+// A query finding no record is cached too, along with its count of rows.
+func (p *Plugin) store(ctx context.Context, db *gorm.DB, key string, ttl time.Duration) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(db.RowsAffected); err != nil {
+		return
+	}
+	if db.RowsAffected > 0 {
+		if err := enc.Encode(db.Statement.Dest); err != nil {
+			return
+		}
+	}
+	_ = p.cache.Put(ctx, key, buf.String(), ttl)
+}
+
+// tables lists the tables of the statement and the ones named in its SQL, sorted and without duplicates.
+func tables(stmt *gorm.Statement) []string {
+	seen := make(map[string]struct{})
+	if stmt.Table != "" {
+		seen[stmt.Table] = struct{}{}
+	}
+	for _, match := range tablePattern.FindAllStringSubmatch(stmt.SQL.String(), -1) {
+		name := strings.Trim(match[1], "`\"")
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			name = strings.Trim(name[i+1:], "`\"")
+		}
+		if name != "" {
+			seen[name] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func inTransaction(db *gorm.DB) bool {
+	_, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok
+}
+
+var generationSeq uint64
+
+// newGeneration is unique within the process and, with the time, very unlikely to collide with another.
+func newGeneration() string {
+	seq := atomic.AddUint64(&generationSeq, 1)
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + "." + strconv.FormatUint(seq, 36)
+}
@@ -0,0 +1,199 @@
+package querycache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/cache"
+	"github.com/go-monsters/monster/pkg/cache/memory"
+	"github.com/go-monsters/monster/pkg/orm"
+	"github.com/go-monsters/monster/pkg/orm/sqlite"
+	"gorm.io/gorm"
+)
+
+type user struct {
+	ID   uint
+	Name string `gorm:"unique"`
+}
+
+// countingCache counts the generations renewed per table.
+type countingCache struct {
+	cache.Cache
+	mu      sync.Mutex
+	renewed map[string]int
+}
+
+func (c *countingCache) Put(ctx context.Context, key string, val interface{}, timeout time.Duration) error {
+	if table := strings.TrimPrefix(key, DefaultPrefix+"t:"); table != key {
+		c.mu.Lock()
+		c.renewed[table]++
+		c.mu.Unlock()
+	}
+	return c.Cache.Put(ctx, key, val, timeout)
+}
+
+func (c *countingCache) renewals(table string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.renewed[table]
+}
+
+func newTestDB(t *testing.T) (orm.Database, *countingCache) {
+	t.Helper()
+	mc := memory.NewMemoryCache()
+	if err := mc.Start(`{"interval": 0}`); err != nil {
+		t.Fatal(err)
+	}
+	c := &countingCache{Cache: mc, renewed: make(map[string]int)}
+	ctx := context.Background()
+	db, err := sqlite.Open(ctx, sqlite.Config{InMemory: true}, orm.WithPlugins(New(c)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.GetConnection(ctx).AutoMigrate(&user{}); err != nil {
+		t.Fatal(err)
+	}
+	return db, c
+}
+
+// insertRaw writes a user without going through the plugin.
+func insertRaw(t *testing.T, db orm.Database, name string) {
+	t.Helper()
+	sqlDB, err := db.GetConnection(context.Background()).DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqlDB.Exec("INSERT INTO users (name) VALUES (?)", name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCachedQuery(t *testing.T) {
+	db, _ := newTestDB(t)
+	ctx := context.Background()
+	conn := db.GetConnection(ctx)
+	if err := conn.Create(&user{Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var first []user
+	if err := conn.Scopes(Cached(time.Minute)).Find(&first).Error; err != nil {
+		t.Fatal(err)
+	}
+	// a write not made through the plugin is not seen until the tables are invalidated
+	insertRaw(t, db, "b")
+	var second []user
+	if err := conn.Scopes(Cached(time.Minute)).Find(&second).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 1 || len(second) != 1 {
+		t.Fatalf("got %d then %d users, want the cached result of 1 user", len(first), len(second))
+	}
+
+	if err := conn.Create(&user{Name: "c"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var third []user
+	if err := conn.Scopes(Cached(time.Minute)).Find(&third).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(third) != 3 {
+		t.Fatalf("got %d users after a create, want 3", len(third))
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	errRollback := errors.New("rollback")
+	tests := []struct {
+		name     string
+		existing string // a user inserted before the write
+		write    func(ctx context.Context, db orm.Database) error
+		wantErr  bool
+		want     int
+	}{
+		{
+			name: "statement",
+			write: func(ctx context.Context, db orm.Database) error {
+				return db.GetConnection(ctx).Create(&user{Name: "a"}).Error
+			},
+			want: 1,
+		},
+		{
+			name:     "failed statement",
+			existing: "a",
+			write: func(ctx context.Context, db orm.Database) error {
+				return db.GetConnection(ctx).Create(&user{Name: "a"}).Error
+			},
+			wantErr: true,
+			want:    0,
+		},
+		{
+			name: "committed transaction",
+			write: func(ctx context.Context, db orm.Database) error {
+				return orm.WithTx(ctx, db, func(ctx context.Context) error {
+					return db.GetConnection(ctx).Create(&user{Name: "a"}).Error
+				})
+			},
+			want: 2,
+		},
+		{
+			name: "rolled back transaction",
+			write: func(ctx context.Context, db orm.Database) error {
+				err := orm.WithTx(ctx, db, func(ctx context.Context) error {
+					if err := db.GetConnection(ctx).Create(&user{Name: "a"}).Error; err != nil {
+						return err
+					}
+					return errRollback
+				})
+				if errors.Is(err, errRollback) {
+					return nil
+				}
+				return err
+			},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, c := newTestDB(t)
+			if tt.existing != "" {
+				insertRaw(t, db, tt.existing)
+			}
+			if err := tt.write(context.Background(), db); (err != nil) != tt.wantErr {
+				t.Fatalf("write error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := c.renewals("users"); got != tt.want {
+				t.Errorf("users renewed %d times, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTables(t *testing.T) {
+	tests := []struct {
+		table string
+		sql   string
+		want  []string
+	}{
+		{table: "users", sql: "SELECT * FROM `users`", want: []string{"users"}},
+		{sql: `SELECT * FROM "public"."users" JOIN orders ON orders.user_id = users.id`, want: []string{"orders", "users"}},
+		{sql: "INSERT INTO users (name) VALUES (?)", want: []string{"users"}},
+		{sql: "update `app`.`users` set name = ?", want: []string{"users"}},
+		{table: "users", sql: "SELECT 1", want: []string{"users"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			stmt := &gorm.Statement{Table: tt.table}
+			stmt.SQL.WriteString(tt.sql)
+			got := tables(stmt)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("tables = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package orm
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// DefaultHealthCheckInterval is the time between two pings of every replica.
+var DefaultHealthCheckInterval = 10 * time.Second
+
+type primaryKey struct{}
+
+// WithPrimary returns a context whose reads go to the primary,
+// typically to read a row just written before it reaches the replicas.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsePrimary reports whether ctx was returned by WithPrimary.
+func UsePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// Session returns a session of db bound to ctx, reading from the primary if UsePrimary(ctx).
+// Writes and transactions always go to the primary.
+func Session(ctx context.Context, db *gorm.DB) *gorm.DB {
+	db = db.WithContext(ctx)
+	if UsePrimary(ctx) {
+		db = db.Clauses(dbresolver.Write)
+	}
+	return db
+}
+
+// replicaPolicy balances the reads between the healthy replicas in round robin.
+// The last pool is the primary, used when no replica is healthy, as before the first health check.
+type replicaPolicy struct {
+	next    uint32
+	healthy sync.Map // gorm.ConnPool => struct{}
+	done    chan struct{}
+}
+
+func newReplicaPolicy() *replicaPolicy {
+	return &replicaPolicy{done: make(chan struct{})}
+}
+
+func (p *replicaPolicy) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	replicas := pools[:len(pools)-1]
+	for range replicas {
+		pool := replicas[atomic.AddUint32(&p.next, 1)%uint32(len(replicas))]
+		if _, ok := p.healthy.Load(pool); ok {
+			return pool
+		}
+	}
+	return pools[len(pools)-1]
+}
+
+// healthCheck pings the replicas at once and then every interval, taking out the ones failing
+// and putting back the ones answering again.
+func (p *replicaPolicy) healthCheck(replicas []gorm.ConnPool, interval time.Duration) {
+	for {
+		for _, pool := range replicas {
+			pinger, ok := pool.(interface{ PingContext(context.Context) error })
+			if !ok {
+				p.healthy.Store(pool, struct{}{})
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if err := pinger.PingContext(ctx); err != nil {
+				p.healthy.Delete(pool)
+			} else {
+				p.healthy.Store(pool, struct{}{})
+			}
+			cancel()
+		}
+
+		select {
+		case <-p.done:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// replicaPlugin keeps the policy reachable from the *gorm.DB, so that Close stops its health check.
+type replicaPlugin struct {
+	policy *replicaPolicy
+}
+
+const replicaPluginName = "monster:replicas"
+
+func (replicaPlugin) Name() string {
+	return replicaPluginName
+}
+
+func (replicaPlugin) Initialize(*gorm.DB) error {
+	return nil
+}
+
+// replicaDialector keeps the pool of a replica whose dialector failed to query it on initialization,
+// the replica is used once it answers the health check.
+type replicaDialector struct {
+	gorm.Dialector
+}
+
+func (d replicaDialector) Initialize(db *gorm.DB) error {
+	err := d.Dialector.Initialize(db)
+	if err != nil && db.ConnPool != nil {
+		db.Logger.Warn(context.Background(), "the replica is not reachable, it waits for the health check: %v", err)
+		return nil
+	}
+	return err
+}
+
+// primaryDialector hands the pool of the primary to dbresolver, which opens its own pool otherwise.
+type primaryDialector struct {
+	gorm.Dialector
+	pool gorm.ConnPool
+}
+
+func (d primaryDialector) Initialize(db *gorm.DB) error {
+	db.ConnPool = d.pool
+	return nil
+}
+
+// useReplicas routes the reads of db to the replicas, or to the primary pool of db while none is healthy.
+// The replicas are opened without a ping, so that a replica down doesn't fail the connection.
+func useReplicas(db *gorm.DB, dialect func(address string) gorm.Dialector, o Options) error {
+	primary := db.ConnPool
+	if prepared, ok := primary.(*gorm.PreparedStmtDB); ok {
+		primary = prepared.ConnPool
+	}
+	dialectors := make([]gorm.Dialector, 0, len(o.Replicas)+1)
+	for _, replica := range o.Replicas {
+		dialectors = append(dialectors, replicaDialector{Dialector: dialect(replica)})
+	}
+	dialectors = append(dialectors, primaryDialector{Dialector: db.Dialector, pool: primary})
+
+	policy := newReplicaPolicy()
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: dialectors, Policy: policy}).
+		SetMaxIdleConns(o.MaxIdleConns).
+		SetMaxOpenConns(o.MaxOpenConns).
+		SetConnMaxLifetime(o.ConnMaxLifetime).
+		SetConnMaxIdleTime(o.ConnMaxIdleTime)
+	if err := db.Use(resolver); err != nil {
+		return err
+	}
+	if err := db.Use(replicaPlugin{policy: policy}); err != nil {
+		return err
+	}
+
+	var replicas []gorm.ConnPool
+	_ = resolver.Call(func(pool gorm.ConnPool) error {
+		if pool != primary {
+			replicas = append(replicas, pool)
+		}
+		return nil
+	})
+	interval := o.HealthCheckInterval
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+	go policy.healthCheck(replicas, interval)
+	return nil
+}
+
+// Close closes the connection pools of db, including the replicas and their health check.
+func Close(db *gorm.DB) error {
+	if plugin, ok := db.Config.Plugins[replicaPluginName].(replicaPlugin); ok {
+		close(plugin.policy.done)
+	}
+	if resolver, ok := db.Config.Plugins[(&dbresolver.DBResolver{}).Name()].(*dbresolver.DBResolver); ok {
+		_ = resolver.Call(func(pool gorm.ConnPool) error {
+			if closer, ok := pool.(interface{ Close() error }); ok {
+				_ = closer.Close()
+			}
+			return nil
+		})
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
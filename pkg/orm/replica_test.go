@@ -0,0 +1,113 @@
+package orm
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sqlite "go.elastic.co/apm/module/apmgormv2/v2/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type fakePool struct {
+	gorm.ConnPool
+	name string
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int
+		healthy  []int
+		want     []string
+	}{
+		{name: "no healthy replica", replicas: 2, want: []string{"primary", "primary", "primary"}},
+		{name: "round robin", replicas: 2, healthy: []int{0, 1}, want: []string{"1", "0", "1", "0"}},
+		{name: "unhealthy skipped", replicas: 3, healthy: []int{2}, want: []string{"2", "2", "2"}},
+		{name: "single replica", replicas: 1, healthy: []int{0}, want: []string{"0", "0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pools := make([]gorm.ConnPool, 0, tt.replicas+1)
+			for i := 0; i < tt.replicas; i++ {
+				pools = append(pools, &fakePool{name: string(rune('0' + i))})
+			}
+			pools = append(pools, &fakePool{name: "primary"})
+			p := newReplicaPolicy()
+			for _, i := range tt.healthy {
+				p.healthy.Store(pools[i], struct{}{})
+			}
+
+			for i, want := range tt.want {
+				if got := p.Resolve(pools).(*fakePool).name; got != want {
+					t.Errorf("Resolve #%d = %s, want %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+// newRecords creates a sqlite file whose only record is named name.
+func newRecords(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name+".db")
+	db, err := Connect(context.Background(), sqlite.Open, path, NewOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(db)
+	if err := db.Exec("CREATE TABLE records (name TEXT)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("INSERT INTO records VALUES (?)", name).Error; err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReplicas(t *testing.T) {
+	tests := []struct {
+		name        string
+		replica     func(t *testing.T) string
+		primaryRead bool
+		want        string
+	}{
+		{name: "healthy replica", replica: func(t *testing.T) string { return newRecords(t, "replica") }, want: "replica"},
+		{name: "unreachable replica", replica: unreachable, want: "primary"},
+		{name: "read from the primary", replica: func(t *testing.T) string { return newRecords(t, "replica") }, primaryRead: true, want: "primary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db, err := Connect(ctx, sqlite.Open, newRecords(t, "primary"),
+				NewOptions(WithReplicas(tt.replica(t)), WithHealthCheckInterval(10*time.Millisecond)))
+			if err != nil {
+				t.Fatalf("Connect error = %v, want the connection made whatever the state of the replica", err)
+			}
+			defer Close(db)
+			// wait for the first health check
+			time.Sleep(50 * time.Millisecond)
+
+			if tt.primaryRead {
+				ctx = WithPrimary(ctx)
+			}
+			var got string
+			if err := Session(ctx, db).Table("records").Select("name").Scan(&got).Error; err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("read from %s, want %s", got, tt.want)
+			}
+			// writes go to the primary
+			if err := Session(ctx, db).Exec("INSERT INTO records VALUES ('written')").Error; err != nil {
+				t.Fatal(err)
+			}
+			var count int64
+			_ = Session(WithPrimary(ctx), db).Table("records").Count(&count)
+			if count != 2 {
+				t.Errorf("%d records in the primary, want 2", count)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/orm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var ErrNotFound = merror.Error("the record isn't exist")
+
+// Repository gives typed access to the table of the model T, every method returns ErrNotFound
+// when the record it looks for doesn't exist.
+type Repository[T any] struct {
+	db orm.Database
+
+	pkOnce    sync.Once
+	pk        string
+	pkField   *schema.Field
+	createdAt []string // the columns set on creation only, which Update leaves alone
+	pkErr     error
+}
+
+func New[T any](db orm.Database) *Repository[T] {
+	return &Repository[T]{db: db}
+}
+
+func (r *Repository[T]) FindByID(ctx context.Context, id interface{}) (*T, error) {
+	pk, err := r.primaryKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.FindOne(ctx, Eq(pk, id))
+}
+
+// FindOne returns the first record matching the specs.
+func (r *Repository[T]) FindOne(ctx context.Context, specs ...Spec) (*T, error) {
+	var entity T
+	err := apply(r.conn(ctx), specs).Take(&entity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, merror.Wrap(err, "could not find the record")
+	}
+	return &entity, nil
+}
+
+func (r *Repository[T]) FindWhere(ctx context.Context, specs ...Spec) ([]T, error) {
+	var entities []T
+	if err := apply(r.conn(ctx), specs).Find(&entities).Error; err != nil {
+		return nil, merror.Wrap(err, "could not find the records")
+	}
+	return entities, nil
+}
+
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	return merror.Wrap(r.conn(ctx).Create(entity).Error, "could not create the record")
+}
+
+// Update writes every field of entity, including the zero ones, to its existing record,
+// except the creation time.
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	pk, err := r.primaryKey(ctx)
+	if err != nil {
+		return err
+	}
+	id, zero := r.pkField.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+	if zero {
+		return ErrNotFound
+	}
+	res := r.conn(ctx).Model(entity).Select("*").Omit(r.createdAt...).Updates(entity)
+	if res.Error != nil {
+		return merror.Wrap(res.Error, "could not update the record")
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+	// mysql counts the changed rows, not the matched ones, unless clientFoundRows is set:
+	// an unchanged record is not a missing one, the replicas may not have it yet
+	found, err := r.Exists(orm.WithPrimary(ctx), Eq(pk, id))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repository[T]) Delete(ctx context.Context, entity *T) error {
+	res := r.conn(ctx).Delete(entity)
+	if res.Error != nil {
+		return merror.Wrap(res.Error, "could not delete the record")
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteWhere deletes the records matching the specs and returns their number.
+func (r *Repository[T]) DeleteWhere(ctx context.Context, specs ...Spec) (int64, error) {
+	var entity T
+	res := apply(r.conn(ctx), specs).Delete(&entity)
+	if res.Error != nil {
+		return 0, merror.Wrap(res.Error, "could not delete the records")
+	}
+	return res.RowsAffected, nil
+}
+
+func (r *Repository[T]) Exists(ctx context.Context, specs ...Spec) (bool, error) {
+	var entity T
+	var found []int
+	err := apply(r.conn(ctx).Model(&entity), specs).Select("1").Limit(1).Find(&found).Error
+	if err != nil {
+		return false, merror.Wrap(err, "could not check the existence of the record")
+	}
+	return len(found) > 0, nil
+}
+
+func (r *Repository[T]) Count(ctx context.Context, specs ...Spec) (int64, error) {
+	var entity T
+	var count int64
+	if err := apply(r.conn(ctx).Model(&entity), specs).Count(&count).Error; err != nil {
+		return 0, merror.Wrap(err, "could not count the records")
+	}
+	return count, nil
+}
+
+func (r *Repository[T]) conn(ctx context.Context) *gorm.DB {
+	return r.db.GetConnection(ctx)
+}
+
+func (r *Repository[T]) primaryKey(ctx context.Context) (string, error) {
+	r.pkOnce.Do(func() {
+		var entity T
+		stmt := &gorm.Statement{DB: r.conn(ctx)}
+		if err := stmt.Parse(&entity); err != nil {
+			r.pkErr = merror.Wrap(err, "could not parse the model")
+			return
+		}
+		if stmt.Schema.PrioritizedPrimaryField == nil {
+			r.pkErr = merror.Errorf("the model %s has no primary key", stmt.Schema.Name)
+			return
+		}
+		r.pk = stmt.Schema.PrioritizedPrimaryField.DBName
+		r.pkField = stmt.Schema.PrioritizedPrimaryField
+		for _, field := range stmt.Schema.Fields {
+			if field.AutoCreateTime > 0 && field.DBName != "" {
+				r.createdAt = append(r.createdAt, field.DBName)
+			}
+		}
+	})
+	return r.pk, r.pkErr
+}
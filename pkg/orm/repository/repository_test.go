@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/orm/sqlite"
+)
+
+type user struct {
+	ID        int `gorm:"primaryKey"`
+	Name      string
+	Age       int
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type noKey struct {
+	Name string
+}
+
+func newTestRepository(t *testing.T) (*Repository[user], context.Context) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := sqlite.Open(ctx, sqlite.Config{InMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.GetConnection(ctx).AutoMigrate(&user{}); err != nil {
+		t.Fatal(err)
+	}
+	r := New[user](db)
+	for _, u := range []user{
+		{ID: 1, Name: "ann", Age: 30, Active: true},
+		{ID: 2, Name: "bob", Age: 17},
+		{ID: 3, Name: "cid", Age: 45, Active: true},
+		{ID: 4, Name: "dan", Age: 17, Active: true},
+	} {
+		u := u
+		if err := r.Create(ctx, &u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r, ctx
+}
+
+func names(users []user) []string {
+	names := []string{}
+	for _, u := range users {
+		names = append(names, u.Name)
+	}
+	return names
+}
+
+func TestFindWhere(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []Spec
+		want  []string
+	}{
+		{name: "all", specs: []Spec{OrderBy("id", false)}, want: []string{"ann", "bob", "cid", "dan"}},
+		{name: "where", specs: []Spec{Where("age > ?", 18), OrderBy("id", false)}, want: []string{"ann", "cid"}},
+		{name: "eq", specs: []Spec{Eq("age", 17), OrderBy("id", false)}, want: []string{"bob", "dan"}},
+		{name: "in", specs: []Spec{In("id", []int{1, 3}), OrderBy("id", true)}, want: []string{"cid", "ann"}},
+		{name: "and", specs: []Spec{And(Eq("age", 17), Eq("active", true))}, want: []string{"dan"}},
+		{name: "or", specs: []Spec{Or(Eq("name", "ann"), Eq("name", "bob")), OrderBy("id", false)}, want: []string{"ann", "bob"}},
+		{
+			name:  "or grouped with and",
+			specs: []Spec{Eq("active", true), Or(Eq("age", 17), Eq("age", 45)), OrderBy("id", false)},
+			want:  []string{"cid", "dan"},
+		},
+		{name: "not", specs: []Spec{Not(Eq("age", 17)), OrderBy("id", false)}, want: []string{"ann", "cid"}},
+		{name: "not or", specs: []Spec{Not(Or(Eq("id", 1), Eq("id", 2))), OrderBy("id", false)}, want: []string{"cid", "dan"}},
+		{name: "page", specs: []Spec{OrderBy("id", false), Limit(2), Offset(1)}, want: []string{"bob", "cid"}},
+		{name: "none", specs: []Spec{Eq("name", "eve")}, want: []string{}},
+	}
+	r, ctx := newTestRepository(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.FindWhere(ctx, tt.specs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(names(got), tt.want) {
+				t.Errorf("FindWhere = %v, want %v", names(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	r, ctx := newTestRepository(t)
+	tests := []struct {
+		name    string
+		find    func() (*user, error)
+		want    string
+		wantErr error
+	}{
+		{name: "by id", find: func() (*user, error) { return r.FindByID(ctx, 2) }, want: "bob"},
+		{name: "by missing id", find: func() (*user, error) { return r.FindByID(ctx, 9) }, wantErr: ErrNotFound},
+		{name: "one", find: func() (*user, error) { return r.FindOne(ctx, Eq("age", 45)) }, want: "cid"},
+		{name: "one missing", find: func() (*user, error) { return r.FindOne(ctx, Eq("age", 99)) }, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.find()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Name != tt.want {
+				t.Errorf("found %s, want %s", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		entity  user
+		wantErr error
+	}{
+		{name: "changed", entity: user{ID: 1, Name: "ann", Age: 31, Active: true}},
+		{name: "zero fields written", entity: user{ID: 1, Name: "ann"}},
+		{name: "unchanged", entity: user{ID: 1, Name: "ann", Age: 30, Active: true}},
+		{name: "missing", entity: user{ID: 9, Name: "eve"}, wantErr: ErrNotFound},
+		{name: "no primary key", entity: user{Name: "eve"}, wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ctx := newTestRepository(t)
+			before, _ := r.FindByID(ctx, 1)
+
+			entity := tt.entity
+			if err := r.Update(ctx, &entity); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Update error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			got, err := r.FindByID(ctx, tt.entity.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != tt.entity.Name || got.Age != tt.entity.Age || got.Active != tt.entity.Active {
+				t.Errorf("updated record = %+v, want %+v", got, tt.entity)
+			}
+			// the entity carries a zero creation time, which must not overwrite the stored one
+			if !got.CreatedAt.Equal(before.CreatedAt) {
+				t.Errorf("CreatedAt = %s, want %s", got.CreatedAt, before.CreatedAt)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	r, ctx := newTestRepository(t)
+	if err := r.Delete(ctx, &user{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete(ctx, &user{ID: 1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete error = %v, want %v", err, ErrNotFound)
+	}
+
+	n, err := r.DeleteWhere(ctx, Eq("age", 17))
+	if err != nil || n != 2 {
+		t.Errorf("DeleteWhere = %d, %v, want 2", n, err)
+	}
+	count, err := r.Count(ctx)
+	if err != nil || count != 1 {
+		t.Errorf("Count = %d, %v, want 1", count, err)
+	}
+}
+
+func TestExistsCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		specs     []Spec
+		wantFound bool
+		wantCount int64
+	}{
+		{name: "all", wantFound: true, wantCount: 4},
+		{name: "some", specs: []Spec{Eq("active", true)}, wantFound: true, wantCount: 3},
+		{name: "none", specs: []Spec{Eq("age", 99)}},
+	}
+	r, ctx := newTestRepository(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := r.Exists(ctx, tt.specs...)
+			if err != nil || found != tt.wantFound {
+				t.Errorf("Exists = %v, %v, want %v", found, err, tt.wantFound)
+			}
+			count, err := r.Count(ctx, tt.specs...)
+			if err != nil || count != tt.wantCount {
+				t.Errorf("Count = %d, %v, want %d", count, err, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestNoPrimaryKey(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqlite.Open(ctx, sqlite.Config{InMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r := New[noKey](db)
+	if _, err := r.FindByID(ctx, 1); err == nil {
+		t.Error("FindByID error = nil, want the missing primary key")
+	}
+	if err := r.Update(ctx, &noKey{}); err == nil {
+		t.Error("Update error = nil, want the missing primary key")
+	}
+}
@@ -0,0 +1,105 @@
+package repository
+
+import "gorm.io/gorm"
+
+// Spec narrows or shapes a query, specs are composed with And, Or and Not.
+type Spec interface {
+	Apply(db *gorm.DB) *gorm.DB
+}
+
+// SpecFunc is an adapter to use a gorm scope as a Spec.
+type SpecFunc func(db *gorm.DB) *gorm.DB
+
+func (f SpecFunc) Apply(db *gorm.DB) *gorm.DB {
+	return f(db)
+}
+
+// Where filters with a gorm condition, for example Where("age > ?", 18) or Where(&User{Name: "x"}).
+func Where(query interface{}, args ...interface{}) Spec {
+	return SpecFunc(func(db *gorm.DB) *gorm.DB {
+		return db.Where(query, args...)
+	})
+}
+
+// Eq filters the rows whose column equals val.
+func Eq(column string, val interface{}) Spec {
+	return SpecFunc(func(db *gorm.DB) *gorm.DB {
+		return db.Where(map[string]interface{}{column: val})
+	})
+}
+
+// In filters the rows whose column is one of vals.
+func In(column string, vals interface{}) Spec {
+	return SpecFunc(func(db *gorm.DB) *gorm.DB {
+		return db.Where(map[string]interface{}{column: vals})
+	})
+}
+
+func And(specs ...Spec) Spec {
+	return SpecFunc(func(db *gorm.DB) *gorm.DB {
+		return db.Where(group(db, specs...))
+	})
+}
+
+func Or(specs ...Spec) Spec {
+	return SpecFunc(func(db *gorm.DB) *gorm.DB {
+		cond := db.Session(&gorm.Session{NewDB: true})
+		for i, spec := range specs {
+			if i == 0 {
+				cond = cond.Where(group(db, spec))
+			} else {
+				cond = cond.Or(group(db, spec))
+			}
+		}
+		return db.Where(cond)
+	})
+}
+
+func Not(spec Spec) Spec {
+	return SpecFunc(func(db *gorm.DB) *gorm.DB {
+		return db.Not(group(db, spec))
+	})
+}
+
+func OrderBy(column string, desc bool) Spec {
+	return SpecFunc(func(db *gorm.DB) *gorm.DB {
+		if desc {
+			return db.Order(column + " DESC")
+		}
+		return db.Order(column)
+	})
+}
+
+func Limit(n int) Spec {
+	return SpecFunc(func(db *gorm.DB) *gorm.DB {
+		return db.Limit(n)
+	})
+}
+
+func Offset(n int) Spec {
+	return SpecFunc(func(db *gorm.DB) *gorm.DB {
+		return db.Offset(n)
+	})
+}
+
+func Preload(association string, args ...interface{}) Spec {
+	return SpecFunc(func(db *gorm.DB) *gorm.DB {
+		return db.Preload(association, args...)
+	})
+}
+
+// group applies the specs to an empty statement, used as a parenthesized condition of db.
+func group(db *gorm.DB, specs ...Spec) *gorm.DB {
+	cond := db.Session(&gorm.Session{NewDB: true})
+	for _, spec := range specs {
+		cond = spec.Apply(cond)
+	}
+	return cond
+}
+
+func apply(db *gorm.DB, specs []Spec) *gorm.DB {
+	for _, spec := range specs {
+		db = spec.Apply(db)
+	}
+	return db
+}
@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-monsters/monster/internals/logs/merror"
+	"github.com/go-monsters/monster/pkg/orm"
+	sqlite "go.elastic.co/apm/module/apmgormv2/v2/driver/sqlite"
+)
+
+const DefaultBusyTimeout = 5 * time.Second
+
+var memoryDBs uint64
+
+type Config struct {
+	DSN         string        // a "file:" URI given as is to the driver, the other fields are then ignored
+	Path        string        // the database file, ignored in memory
+	InMemory    bool          // the database lives as long as one of its connections is open
+	WAL         bool          // write-ahead log journal mode, readers don't block the writer
+	BusyTimeout time.Duration // wait for a lock before failing with SQLITE_BUSY, DefaultBusyTimeout if 0
+}
+
+type Sqlite struct {
+	*orm.Conn
+}
+
+// New returns a database connecting on the first call of GetConnection.
+// If it can't connect, the returned *gorm.DB carries the error and the next call tries again.
+func New(config Config, opts ...orm.Option) orm.Database {
+	return newSqlite(config, opts)
+}
+
+// Open connects to the database and returns the error if the connection can't be made.
+func Open(ctx context.Context, config Config, opts ...orm.Option) (*Sqlite, error) {
+	s := newSqlite(config, opts)
+	if _, err := s.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newSqlite(config Config, opts []orm.Option) *Sqlite {
+	return &Sqlite{Conn: orm.NewConn("sqlite", sqlite.Open, dsn(config), options(config, opts...))}
+}
+
+func dsn(config Config) string {
+	if config.DSN != "" {
+		return config.DSN
+	}
+	params := url.Values{}
+	busyTimeout := config.BusyTimeout
+	if busyTimeout <= 0 {
+		busyTimeout = DefaultBusyTimeout
+	}
+	params.Set("_busy_timeout", fmt.Sprint(busyTimeout.Milliseconds()))
+	params.Set("_foreign_keys", "1")
+
+	if config.InMemory {
+		// a shared cache lets every connection of the pool see the same database,
+		// the name keeps the databases of different instances apart
+		params.Set("mode", "memory")
+		params.Set("cache", "shared")
+		return "file:monster-" + strconv.FormatUint(atomic.AddUint64(&memoryDBs, 1), 10) + "?" + params.Encode()
+	}
+	if config.WAL {
+		params.Set("_journal_mode", "WAL")
+	}
+	return "file:" + url.PathEscape(config.Path) + "?" + params.Encode()
+}
+
+func options(config Config, opts ...orm.Option) orm.Options {
+	o := orm.NewOptions(opts...)
+	if config.InMemory {
+		// the database is dropped when its last connection is closed, and a single connection
+		// keeps the writers of the shared cache from failing on a table lock instead of waiting
+		o.MaxOpenConns = 1
+		o.MaxIdleConns = 1
+		o.ConnMaxLifetime = 0
+		o.ConnMaxIdleTime = 0
+	}
+	return o
+}
+
+// ConfigOf reads the sqlite config from the orm config: a DSN starting with "file:" is given as is
+// to the driver, otherwise the file is DSN or Database, ":memory:" or the param mode=memory keeps it
+// in memory, the params journal_mode=WAL and busy_timeout (milliseconds) set WAL and BusyTimeout.
+func ConfigOf(config orm.Config) (Config, error) {
+	if strings.HasPrefix(config.DSN, "file:") {
+		return Config{DSN: config.DSN, InMemory: strings.Contains(config.DSN, "mode=memory")}, nil
+	}
+	c := Config{Path: config.DSN}
+	if c.Path == "" {
+		c.Path = config.Database
+	}
+	c.InMemory = c.Path == ":memory:" || config.Params["mode"] == "memory"
+	c.WAL = strings.EqualFold(config.Params["journal_mode"], "WAL")
+	if busyTimeout, ok := config.Params["busy_timeout"]; ok {
+		ms, err := strconv.Atoi(busyTimeout)
+		if err != nil {
+			return c, merror.Wrapf(err, "invalid busy_timeout, it must be integer: %s", busyTimeout)
+		}
+		c.BusyTimeout = time.Duration(ms) * time.Millisecond
+	}
+	if c.Path == "" && !c.InMemory {
+		return c, merror.Error("the sqlite config misses the database file")
+	}
+	return c, nil
+}
+
+func init() {
+	orm.RegisterDatabaseImpl("sqlite", func(config orm.Config) (orm.Database, error) {
+		c, err := ConfigOf(config)
+		if err != nil {
+			return nil, err
+		}
+		return New(c, config.Options()...), nil
+	})
+}
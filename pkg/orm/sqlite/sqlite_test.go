@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-monsters/monster/pkg/orm"
+)
+
+func TestConfigOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  orm.Config
+		want    Config
+		wantErr bool
+	}{
+		{name: "file", config: orm.Config{DSN: "data/app.db"}, want: Config{Path: "data/app.db"}},
+		{name: "database", config: orm.Config{Database: "app.db"}, want: Config{Path: "app.db"}},
+		{name: "memory", config: orm.Config{DSN: ":memory:"}, want: Config{Path: ":memory:", InMemory: true}},
+		{name: "memory param", config: orm.Config{Database: "app", Params: map[string]string{"mode": "memory"}}, want: Config{Path: "app", InMemory: true}},
+		{
+			name:   "wal and busy timeout",
+			config: orm.Config{DSN: "app.db", Params: map[string]string{"journal_mode": "wal", "busy_timeout": "200"}},
+			want:   Config{Path: "app.db", WAL: true, BusyTimeout: 200 * time.Millisecond},
+		},
+		{name: "file uri", config: orm.Config{DSN: "file:app.db?mode=memory"}, want: Config{DSN: "file:app.db?mode=memory", InMemory: true}},
+		{name: "invalid busy timeout", config: orm.Config{DSN: "app.db", Params: map[string]string{"busy_timeout": "1s"}}, wantErr: true},
+		{name: "no file", config: orm.Config{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConfigOf(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConfigOf error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConfigOf = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   []string
+		not    []string
+	}{
+		{name: "as is", config: Config{DSN: "file:app.db?cache=private", Path: "other.db"}, want: []string{"file:app.db?cache=private"}},
+		{name: "defaults", config: Config{Path: "app.db"}, want: []string{"file:app.db?", "_busy_timeout=5000", "_foreign_keys=1"}, not: []string{"_journal_mode"}},
+		{name: "wal", config: Config{Path: "app.db", WAL: true, BusyTimeout: time.Second}, want: []string{"_journal_mode=WAL", "_busy_timeout=1000"}},
+		{name: "escaped path", config: Config{Path: "my data/a?b#c%.db"}, want: []string{"file:my%20data%2Fa%3Fb%23c%25.db?"}},
+		{name: "memory", config: Config{Path: "app.db", InMemory: true, WAL: true}, want: []string{"file:monster-", "mode=memory", "cache=shared"}, not: []string{"app.db", "_journal_mode"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dsn(tt.config)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("dsn = %s, want it to contain %s", got, want)
+				}
+			}
+			for _, not := range tt.not {
+				if strings.Contains(got, not) {
+					t.Errorf("dsn = %s, want it without %s", got, not)
+				}
+			}
+		})
+	}
+	if dsn(Config{InMemory: true}) == dsn(Config{InMemory: true}) {
+		t.Error("two databases in memory have the same name")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		wantOpen int
+	}{
+		{name: "file", config: Config{Path: "app.db"}, wantOpen: 7},
+		{name: "memory", config: Config{InMemory: true}, wantOpen: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if o := options(tt.config, orm.WithMaxOpenConns(7)); o.MaxOpenConns != tt.wantOpen {
+				t.Errorf("MaxOpenConns = %d, want %d", o.MaxOpenConns, tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "memory", config: Config{InMemory: true}},
+		{name: "file", config: Config{Path: filepath.Join(t.TempDir(), "my data.db")}},
+		{name: "wal", config: Config{Path: filepath.Join(t.TempDir(), "wal.db"), WAL: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db, err := Open(ctx, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			conn := db.GetConnection(ctx)
+			if err := conn.Exec("CREATE TABLE records (id INTEGER)").Error; err != nil {
+				t.Fatal(err)
+			}
+			// a write in a transaction is seen by the next read of the pool
+			err = orm.WithTx(ctx, db, func(ctx context.Context) error {
+				return db.GetConnection(ctx).Exec("INSERT INTO records VALUES (1)").Error
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var count int64
+			if err := db.GetConnection(ctx).Table("records").Count(&count).Error; err != nil || count != 1 {
+				t.Errorf("Count = %d, %v, want 1", count, err)
+			}
+		})
+	}
+}
+
+func TestNewDatabase(t *testing.T) {
+	db, err := orm.NewDatabase("sqlite", `{"dsn": ":memory:"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.GetConnection(context.Background()).Exec("SELECT 1").Error; err != nil {
+		t.Error(err)
+	}
+	_ = db.(*Sqlite).Close()
+}
@@ -0,0 +1,178 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct {
+	db Database
+}
+
+// keyOf keys the transactions of an adapter by the Conn it embeds, whose GetConnection looks for them.
+func keyOf(db Database) txKey {
+	if c, ok := db.(interface{ conn() *Conn }); ok {
+		return txKey{db: c.conn()}
+	}
+	return txKey{db: db}
+}
+
+type txOptions struct {
+	sql     sql.TxOptions
+	retries int
+	backoff time.Duration
+}
+
+type TxOption func(*txOptions)
+
+// TxIsolation sets the isolation level of the transaction, the default one of the database is used otherwise.
+func TxIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) {
+		o.sql.Isolation = level
+	}
+}
+
+func TxReadOnly() TxOption {
+	return func(o *txOptions) {
+		o.sql.ReadOnly = true
+	}
+}
+
+// TxRetries runs the transaction again, up to retries times, when it fails on a serialization failure
+// or a deadlock. The wait before the first retry is backoff, doubled after every retry.
+func TxRetries(retries int, backoff time.Duration) TxOption {
+	return func(o *txOptions) {
+		o.retries = retries
+		o.backoff = backoff
+	}
+}
+
+// Tx returns the transaction of db carried by ctx, or nil outside of WithTx.
+// The adapters return it from GetConnection, so every call made with ctx joins the transaction.
+func Tx(ctx context.Context, db Database) *gorm.DB {
+	tx, _ := ctx.Value(keyOf(db)).(*gorm.DB)
+	return tx
+}
+
+// WithTx runs fn in a transaction of db, committed if fn returns nil and rolled back otherwise.
+// The transaction is carried by the context given to fn, so db.GetConnection(ctx) joins it.
+// A nested WithTx creates a savepoint, rolled back alone if the nested fn fails; the options
+// only apply to the outermost transaction.
+func WithTx(ctx context.Context, db Database, fn func(ctx context.Context) error, opts ...TxOption) error {
+	var o txOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	run := func() error {
+		hooks := &commitHooks{parent: hooksOf(ctx)}
+		err := db.GetConnection(ctx).Transaction(func(tx *gorm.DB) error {
+			hooks.pool = tx.Statement.ConnPool
+			ctx := context.WithValue(ctx, keyOf(db), tx)
+			return fn(context.WithValue(ctx, commitHooksKey{}, hooks))
+		}, &o.sql)
+		if err == nil {
+			hooks.committed()
+		}
+		return err
+	}
+	if Tx(ctx, db) != nil {
+		return run()
+	}
+
+	backoff := o.backoff
+	for attempt := 0; ; attempt++ {
+		err := run()
+		if err == nil || attempt >= o.retries || !IsRetryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// AfterCommit runs fn once the transaction db is executed in commits, if it was started by WithTx.
+// It reports whether fn was registered, fn is never run if the transaction is rolled back.
+func AfterCommit(db *gorm.DB, fn func()) bool {
+	for hooks := hooksOf(db.Statement.Context); hooks != nil; hooks = hooks.parent {
+		if hooks.pool == db.Statement.ConnPool {
+			hooks.add(fn)
+			return true
+		}
+	}
+	return false
+}
+
+type commitHooksKey struct{}
+
+// commitHooks are the functions run after the commit of a transaction,
+// the ones of a savepoint are given to its transaction when it is released.
+type commitHooks struct {
+	parent *commitHooks
+	pool   gorm.ConnPool
+
+	mu  sync.Mutex
+	fns []func()
+}
+
+func hooksOf(ctx context.Context) *commitHooks {
+	if ctx == nil {
+		return nil
+	}
+	hooks, _ := ctx.Value(commitHooksKey{}).(*commitHooks)
+	return hooks
+}
+
+func (h *commitHooks) add(fns ...func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fns = append(h.fns, fns...)
+}
+
+func (h *commitHooks) committed() {
+	h.mu.Lock()
+	fns := h.fns
+	h.fns = nil
+	h.mu.Unlock()
+
+	if h.parent != nil && h.parent.pool == h.pool {
+		h.parent.add(fns...)
+		return
+	}
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// IsRetryable reports whether err is a serialization failure or a deadlock,
+// after which the whole transaction may succeed if it runs again.
+func IsRetryable(err error) bool {
+	// postgresql reports the SQLSTATE, 40001 serialization_failure and 40P01 deadlock_detected
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		code := state.SQLState()
+		return code == "40001" || code == "40P01"
+	}
+
+	msg := err.Error()
+	for _, retryable := range []string{
+		"Error 1213", // mysql deadlock
+		"Error 1205", // mysql lock wait timeout
+		"database is locked",
+	} {
+		if strings.Contains(msg, retryable) {
+			return true
+		}
+	}
+	return false
+}
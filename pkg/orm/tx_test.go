@@ -0,0 +1,250 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	sqlite "go.elastic.co/apm/module/apmgormv2/v2/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestConn(t *testing.T) *Conn {
+	t.Helper()
+	c := NewConn("test", sqlite.Open, filepath.Join(t.TempDir(), "test.db"), NewOptions())
+	t.Cleanup(func() { _ = c.Close() })
+	if err := c.GetConnection(context.Background()).Exec("CREATE TABLE records (id INTEGER PRIMARY KEY)").Error; err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func insert(ctx context.Context, db Database, id int) error {
+	return db.GetConnection(ctx).Exec("INSERT INTO records (id) VALUES (?)", id).Error
+}
+
+func ids(t *testing.T, db Database) []int {
+	t.Helper()
+	got := []int{}
+	if err := db.GetConnection(context.Background()).Table("records").Order("id").Pluck("id", &got).Error; err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sql error " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestWithTx(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name      string
+		fn        func(ctx context.Context, db Database) error
+		wantErr   error
+		want      []int
+		wantHooks []int
+	}{
+		{
+			name: "committed",
+			fn: func(ctx context.Context, db Database) error {
+				return insert(ctx, db, 1)
+			},
+			want: []int{1},
+		},
+		{
+			name: "rolled back",
+			fn: func(ctx context.Context, db Database) error {
+				_ = insert(ctx, db, 1)
+				return errFailed
+			},
+			wantErr: errFailed,
+			want:    []int{},
+		},
+		{
+			name: "savepoint released",
+			fn: func(ctx context.Context, db Database) error {
+				_ = insert(ctx, db, 1)
+				return WithTx(ctx, db, func(ctx context.Context) error { return insert(ctx, db, 2) })
+			},
+			want: []int{1, 2},
+		},
+		{
+			name: "savepoint rolled back alone",
+			fn: func(ctx context.Context, db Database) error {
+				_ = insert(ctx, db, 1)
+				_ = WithTx(ctx, db, func(ctx context.Context) error {
+					_ = insert(ctx, db, 2)
+					return errFailed
+				})
+				return nil
+			},
+			want: []int{1},
+		},
+		{
+			name: "rolled back with its savepoint",
+			fn: func(ctx context.Context, db Database) error {
+				_ = WithTx(ctx, db, func(ctx context.Context) error { return insert(ctx, db, 2) })
+				return errFailed
+			},
+			wantErr: errFailed,
+			want:    []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestConn(t)
+			err := WithTx(context.Background(), db, func(ctx context.Context) error {
+				if Tx(ctx, db) == nil {
+					t.Error("Tx = nil inside WithTx")
+				}
+				return tt.fn(ctx, db)
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WithTx error = %v, want %v", err, tt.wantErr)
+			}
+			if got := ids(t, db); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAfterCommit(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name string
+		fn   func(ctx context.Context, db Database, hook func(context.Context, int)) error
+		want []int
+	}{
+		{
+			name: "committed",
+			fn: func(ctx context.Context, db Database, hook func(context.Context, int)) error {
+				hook(ctx, 1)
+				hook(ctx, 2)
+				return nil
+			},
+			want: []int{1, 2},
+		},
+		{
+			name: "rolled back",
+			fn: func(ctx context.Context, db Database, hook func(context.Context, int)) error {
+				hook(ctx, 1)
+				return errFailed
+			},
+			want: []int{},
+		},
+		{
+			name: "savepoint run on the commit",
+			fn: func(ctx context.Context, db Database, hook func(context.Context, int)) error {
+				return WithTx(ctx, db, func(ctx context.Context) error {
+					hook(ctx, 2)
+					return nil
+				})
+			},
+			want: []int{2},
+		},
+		{
+			name: "savepoint rolled back",
+			fn: func(ctx context.Context, db Database, hook func(context.Context, int)) error {
+				hook(ctx, 1)
+				_ = WithTx(ctx, db, func(ctx context.Context) error {
+					hook(ctx, 2)
+					return errFailed
+				})
+				return nil
+			},
+			want: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestConn(t)
+			ran := []int{}
+			_ = WithTx(context.Background(), db, func(ctx context.Context) error {
+				return tt.fn(ctx, db, func(ctx context.Context, id int) {
+					AfterCommit(db.GetConnection(ctx), func() { ran = append(ran, id) })
+					if len(ran) != 0 {
+						t.Error("the hook ran before the commit")
+					}
+				})
+			})
+			if !reflect.DeepEqual(ran, tt.want) {
+				t.Errorf("hooks ran %v, want %v", ran, tt.want)
+			}
+		})
+	}
+
+	db := newTestConn(t)
+	if AfterCommit(db.GetConnection(context.Background()), func() {}) {
+		t.Error("AfterCommit outside of a transaction = true")
+	}
+}
+
+func TestTxRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		failures     int
+		retries      int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "succeeded after retries", err: sqlStateError("40001"), failures: 2, retries: 3, wantAttempts: 3},
+		{name: "retries exhausted", err: sqlStateError("40P01"), failures: 5, retries: 2, wantErr: true, wantAttempts: 3},
+		{name: "not retryable", err: errors.New("constraint failed"), failures: 1, retries: 3, wantErr: true, wantAttempts: 1},
+		{name: "no retries", err: sqlStateError("40001"), failures: 1, wantErr: true, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestConn(t)
+			var attempts int
+			err := WithTx(context.Background(), db, func(ctx context.Context) error {
+				attempts++
+				if err := insert(ctx, db, attempts); err != nil {
+					return err
+				}
+				if attempts <= tt.failures {
+					return tt.err
+				}
+				return nil
+			}, TxRetries(tt.retries, time.Millisecond))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithTx error = %v, want error %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", attempts, tt.wantAttempts)
+			}
+			// the failed attempts were rolled back
+			if got := ids(t, db); !tt.wantErr && !reflect.DeepEqual(got, []int{attempts}) {
+				t.Errorf("records = %v, want [%d]", got, attempts)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: sqlStateError("40001"), want: true},
+		{err: sqlStateError("40P01"), want: true},
+		{err: sqlStateError("23505")},
+		{err: errors.New("Error 1213: Deadlock found when trying to get lock"), want: true},
+		{err: errors.New("Error 1205: Lock wait timeout exceeded"), want: true},
+		{err: errors.New("database is locked"), want: true},
+		{err: gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}